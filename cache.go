@@ -5,6 +5,11 @@
 package eagercache
 
 import (
+	"container/list"
+	"context"
+	"errors"
+	"runtime"
+	"strings"
 	"sync"
 	"time"
 )
@@ -15,6 +20,31 @@ type (
 	// is never validated, at runtime. Returning non-pointers may produce unexpected
 	// results.
 	EntryUpdater func(string) interface{}
+
+	// EntryUpdaterE is the fallible form of EntryUpdater, passed to CreateCacheE. A non-nil error
+	// tells the cache the lookup failed, and that the returned value must not be cached.
+	EntryUpdaterE func(string) (interface{}, error)
+
+	// EntryUpdaterCtx is the context-aware form of EntryUpdaterE, passed to CreateCacheCtx. The
+	// context is the one given to RetrieveCtx, or context.Background for Retrieve and the cleaner.
+	EntryUpdaterCtx func(context.Context, string) (interface{}, error)
+
+	// EntryUpdaterTTL is the form of EntryUpdater passed to CreateCacheTTL, which also dictates how
+	// long the returned value stays fresh. A zero or negative duration means the cache's default.
+	EntryUpdaterTTL func(string) (interface{}, time.Duration)
+
+	// loadFunc is the common shape every updater is adapted to internally
+	loadFunc func(ctx context.Context, key string) (value interface{}, ttl time.Duration, err error)
+)
+
+var (
+	// ErrCacheClosed is returned by the error-returning methods of a cache which has been imploded,
+	// eg: RetrieveCtx, RetrieveOrError and WarmUp
+	ErrCacheClosed = errors.New("eagercache: the cache has been imploded")
+	// ErrNilUpdater is returned by NewCache when it's given a nil updater
+	ErrNilUpdater = errors.New("eagercache: the updater-func must be a non-nil reference to an EntryUpdater")
+	// ErrInvalidExpireRate is returned by NewCache when it's given a zero or negative expireRate
+	ErrInvalidExpireRate = errors.New("eagercache: the expireRate must be positive, entries would be born expired")
 )
 
 // StartCleaner launches a background scrubbing goroutine. The cleaner does a couple things:
@@ -22,16 +52,79 @@ type (
 //  2. Checks if an entry is expired, otherwise it skips that entryj.
 //  3. If the entry has been accessed at least once, it calls that cache's updater func, passing
 //
-// to the updater func, the key of the stale entry.
+// to the updater func, the key of the stale entry. The refreshed entry starts out unaccessed again.
 //  4. If the entry has NOT been accessed at least once, it calls delete on the underlying map,
 //
 // pruning that entry.
-// cleanRate specifies the time between attempts to shrink the underlying maps.
+// cleanRate specifies the time between attempts to shrink the underlying maps, for every cache which
+// wasn't created with its own rate by WithCleanRate.
 func StartCleaner(cleanRate time.Duration) {
+	p.mu.Lock()
 	p.cleanRate = cleanRate
+	p.mu.Unlock()
+	p.wakeScrubber()
+
+	p.launchMu.Lock()
 	scrubberLauncher.Do(func() {
-		go scrubber()
+		p.mu.Lock()
+		p.generation++
+		generation := p.generation
+		p.mu.Unlock()
+
+		p.stop = make(chan struct{})
+		p.done = make(chan struct{})
+		go scrubber(generation, p.stop, p.done)
 	})
+	p.launchMu.Unlock()
+}
+
+// StopCleaner signals the background scrubbing goroutine to exit and blocks until it has returned.
+// A later call to StartCleaner launches a fresh scrubber. Calling StopCleaner when the cleaner isn't
+// running is a no-op.
+func StopCleaner() {
+	_ = Shutdown(context.Background())
+}
+
+// Shutdown is StopCleaner bounded by ctx, for clean shutdowns: it stops the scrubber, then waits for
+// the scrubs underway, and every background refresh they launched, to finish, so their updaters
+// aren't cut off from eg: a database connection which is about to be closed. If ctx is done first,
+// Shutdown returns ctx.Err() and the scrubs are left to finish on their own: CleanerStatus reports the
+// cleaner as running until they have, unless StartCleaner launches a new scrubber meanwhile, which
+// leaves the caches still being scrubbed to the old one until it's done with them. Once Shutdown
+// returns nil the cleaner is quiesced, until StartCleaner is called again. Refreshes started by
+// WithStaleWhileRevalidate belong to their Retrieve, not to the cleaner, so they aren't waited for.
+func Shutdown(ctx context.Context) error {
+	p.launchMu.Lock()
+	defer p.launchMu.Unlock()
+
+	if p.stop == nil {
+		return nil
+	}
+
+	close(p.stop)
+	done := p.done
+
+	p.stop = nil
+	p.done = nil
+	scrubberLauncher = new(sync.Once)
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// CleanerStatus reports when the cleaner last started a scrubbing pass, when it plans to start the
+// next one, and whether it's running at all, eg: to find out why a cache keeps growing. Both times
+// are zero until the cleaner's first pass, and running is false until StartCleaner is called, and
+// again once the cleaner has stopped.
+func CleanerStatus() (lastRun, nextRun time.Time, running bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.lastRun, p.nextRun, p.running
 }
 
 // CreateCache allocates a cache and adds a reference to it to the pool of caches for regular cleaning.
@@ -39,96 +132,1406 @@ func StartCleaner(cleanRate time.Duration) {
 // If an Expired entry was accessed at least once since the last cleaning time, the cleaner will update
 // the entry. If the expired entry was not accessed at least once, it will be removed and looked up next read.
 //
+// The updater func is required and expected to be threadsafe. The expireRate must be positive, all of
+// the CreateCache constructors panic otherwise. See NewCache for a constructor which returns an error
+// instead.
+func CreateCache(expireRate time.Duration, updater EntryUpdater, opts ...Option) *Cache {
+	c, err := NewCache(expireRate, updater, opts...)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	return c
+}
+
+// NewCache is CreateCache for callers which would rather handle an error than a panic: a nil updater
+// returns ErrNilUpdater, and a zero or negative expireRate ErrInvalidExpireRate, without creating or
+// registering a cache. Options which are given invalid arguments still panic, when they're built.
+func NewCache(expireRate time.Duration, updater EntryUpdater, opts ...Option) (*Cache, error) {
+	if updater == nil {
+		return nil, ErrNilUpdater
+	}
+
+	if expireRate <= 0 {
+		return nil, ErrInvalidExpireRate
+	}
+
+	return newCache(expireRate, func(_ context.Context, key string) (interface{}, time.Duration, error) {
+		return updater(key), 0, nil
+	}, opts), nil
+}
+
+// CreateCacheE is CreateCache for updaters which can fail. Whenever the updater returns an error,
+// nothing is stored: a miss leaves the cache untouched and Retrieve returns nil, while a failed
+// background refresh keeps the previous value and expiry so a transient failure can't poison the
+// cache.
+//
+// The updater func is required and expected to be threadsafe.
+func CreateCacheE(expireRate time.Duration, updater EntryUpdaterE, opts ...Option) *Cache {
+	if updater == nil {
+		panic("the updater-func must be a non-nil reference to an EntryUpdaterE")
+	}
+
+	return newCache(expireRate, func(_ context.Context, key string) (interface{}, time.Duration, error) {
+		v, err := updater(key)
+		return v, 0, err
+	}, opts)
+}
+
+// CreateCacheCtx is CreateCacheE for updaters which take a context, so lookups started through
+// RetrieveCtx can be cancelled or bounded by a deadline. Errors are handled the same as CreateCacheE.
+//
+// The updater func is required and expected to be threadsafe.
+func CreateCacheCtx(expireRate time.Duration, updater EntryUpdaterCtx, opts ...Option) *Cache {
+	if updater == nil {
+		panic("the updater-func must be a non-nil reference to an EntryUpdaterCtx")
+	}
+
+	return newCache(expireRate, func(ctx context.Context, key string) (interface{}, time.Duration, error) {
+		v, err := updater(ctx, key)
+		return v, 0, err
+	}, opts)
+}
+
+// CreateCacheTTL is CreateCache for updaters which decide how long each value stays fresh, eg: from
+// an upstream Cache-Control max-age. A zero or negative TTL from the updater falls back to
+// defaultRate. The per-entry TTL applies both to misses and to the cleaner's refreshes.
+//
 // The updater func is required and expected to be threadsafe.
-func CreateCache(expireRate time.Duration, updater EntryUpdater) *Cache {
+func CreateCacheTTL(defaultRate time.Duration, updater EntryUpdaterTTL, opts ...Option) *Cache {
+	if updater == nil {
+		panic("the updater-func must be a non-nil reference to an EntryUpdaterTTL")
+	}
+
+	return newCache(defaultRate, func(_ context.Context, key string) (interface{}, time.Duration, error) {
+		v, ttl := updater(key)
+		return v, ttl, nil
+	}, opts)
+}
+
+// CreateCacheWithLimit is CreateCache with a hard cap of maxEntries on the number of cached entries.
+// When a miss would grow the cache past maxEntries, the least-recently-used entry is evicted.
+// Recency is tracked on misses and on the first Retrieve of an entry per cleaning interval, so
+// the eviction order is an approximation of true LRU that keeps hits on the read lock.
+func CreateCacheWithLimit(expireRate time.Duration, maxEntries int, updater EntryUpdater, opts ...Option) *Cache {
+	if maxEntries < 1 {
+		panic("maxEntries must be at least 1")
+	}
+
+	return CreateCache(expireRate, updater, append(opts, func(c *Cache) {
+		c.maxEntries = maxEntries
+		c.lru = list.New()
+	})...)
+}
+
+// CreateCacheWithByteLimit is CreateCacheWithLimit, except that the cap is an approximate budget of
+// maxBytes, rather than a number of entries, for caches whose values vary widely in size, eg: rendered
+// images. sizeOf estimates the footprint of a value, it's called with the cache locked whenever an
+// entry is stored or refreshed, so it must be fast. When storing an entry would take the total past
+// maxBytes, the least-recently-used entries are evicted until it fits.
+func CreateCacheWithByteLimit(expireRate time.Duration, maxBytes int64, sizeOf func(interface{}) int64, updater EntryUpdater, opts ...Option) *Cache {
+	if maxBytes < 1 {
+		panic("maxBytes must be at least 1")
+	}
+
+	if sizeOf == nil {
+		panic("the sizeOf-func must be non-nil")
+	}
+
+	return CreateCache(expireRate, updater, append(opts, func(c *Cache) {
+		c.maxBytes = maxBytes
+		c.sizeOf = sizeOf
+		c.lru = list.New()
+	})...)
+}
+
+// CreateCacheSoftHard is CreateCache with two expiries per entry. soft is the usual expireRate, after
+// which the cleaner refreshes accessed entries and evicts the rest, and hard bounds the life of an
+// entry however often it's refreshed, as WithMaxAge does: once hard has passed since a miss filled it,
+// it's evicted regardless of access, so even the hottest keys are rebuilt cold now and then. hard must
+// be longer than soft, or nothing would ever be refreshed.
+func CreateCacheSoftHard(soft, hard time.Duration, updater EntryUpdater, opts ...Option) *Cache {
+	if hard <= soft {
+		panic("the hard expiry must be longer than the soft one")
+	}
+
+	return CreateCache(soft, updater, append(opts, WithMaxAge(hard))...)
+}
+
+// CreateCacheBatchFill is CreateCache for backends which return related records together: on a miss,
+// updater returns the values of several keys at once, which are all stored, under the same lock as
+// key's, so that later Retrieves of the others are hits. The value returned by Retrieve is the one
+// under key, or nil if updater left key out. The related entries start out unaccessed, like those
+// stored by Set. The cleaner's refreshes, and RetrieveMany, still fill one key per updater call,
+// keeping only the value under that key.
+func CreateCacheBatchFill(expireRate time.Duration, updater func(key string) map[string]interface{}, opts ...Option) *Cache {
 	if updater == nil {
-		panic("the updater-func be a non-nil reference to a EntryUpdater")
+		panic("the updater-func must be a non-nil reference to a func(key string) map[string]interface{}")
+	}
+
+	// batchFill is set by an option so that it's in place for the fills of WithInitialKeys
+	return newCache(expireRate, func(_ context.Context, key string) (interface{}, time.Duration, error) {
+		return updater(key)[key], 0, nil
+	}, append(opts, func(c *Cache) {
+		c.batchFill = updater
+	}))
+}
+
+func newCache(expireRate time.Duration, updater loadFunc, opts []Option) *Cache {
+	if expireRate <= 0 {
+		panic("the expireRate must be positive, entries would be born expired")
 	}
 
-	c := &Cache{
+	c := &Cache{cacheState: &cacheState{
+		clock:      realClock{},
+		metrics:    noopMetrics{},
 		expireRate: expireRate,
 		data:       map[string]expirable{},
 		updater:    updater,
 		mu:         new(sync.RWMutex),
+	}}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.finalize {
+		runtime.SetFinalizer(c, finalizeCache)
+	} else {
+		c.handle = c
+	}
+
+	if c.capacity > 0 {
+		c.data = make(map[string]expirable, c.capacity)
 	}
 
 	// register the cache so expired entriesthe cleaner
-	p.addCache(c)
+	if !c.unpooled {
+		p.addCache(c.cacheState)
+	}
+
+	if keys := c.initialKeys; len(keys) > 0 {
+		c.initialKeys = nil
+		if c.waitInitial {
+			_ = c.WarmUp(keys)
+		} else {
+			go func() {
+				_ = c.WarmUp(keys)
+			}()
+		}
+	}
 
 	return c
 }
 
-// Retrieve a value from the cache. On a cache miss, calls the updater func with the provided key
+// Retrieve a value from the cache. On a cache miss, calls the updater func with the provided key.
+// Retrieving from an imploded cache returns nil. The value returned is shared with every other caller
+// and with the cache itself, so treat it as immutable, or clone it with WithCopyOnRetrieve.
 func (c *Cache) Retrieve(key string) interface{} {
+	value, _ := c.RetrieveWithStatus(key)
+	return value
+}
+
+// RetrieveAsync is Retrieve without waiting on the updater, for fanning out lookups: it returns at
+// once, with a channel which receives the value, then nothing more, once it's available. Hits are
+// sent before RetrieveAsync returns, without starting a goroutine, misses are filled by one, sharing
+// the updater call with concurrent fills of the same key, just like Retrieve. The channel is buffered,
+// so it's fine to never receive from it.
+func (c *Cache) RetrieveAsync(key string) <-chan interface{} {
+	ch := make(chan interface{}, 1)
+
 	c.mu.RLock()
-	cached, isCacheHit := c.data[key]
+	cached, isCacheHit := c.data[c.storedKey(key)]
 	c.mu.RUnlock()
 
-	if !isCacheHit || cached.wasAccessedInInterval == false {
-		cached = c.retrieveEntry(key, cached)
+	if isCacheHit && c.readHit(cached) {
+		c.countHits(1)
+		ch <- c.copyOut(cached.value)
+		return ch
 	}
 
-	return cached.value
+	go func() {
+		ch <- c.Retrieve(key)
+	}()
+
+	return ch
 }
 
-// Implode inactivates the cache from the eager cleaning and eager updating processes.
-// Once Implode is called, SUBSEQUENT USES of the cache WILL PANIC
-func (c *Cache) Implode() {
-	if c == nil {
-		return
+// RetrieveWithStatus is Retrieve, additionally reporting where the value came from: StatusHit when
+// it was cached, StatusMiss when the updater had to fill an absent key, or StatusExpiredRefreshed
+// when the updater had to replace an expired entry. A failed fill returns a nil value along with the
+// status of the attempt, as does an imploded cache, with StatusMiss.
+func (c *Cache) RetrieveWithStatus(key string) (value interface{}, status Status) {
+	cached, status, _ := c.retrieve(context.Background(), key, nil)
+	return c.copyOut(cached.value), status
+}
+
+// GetOrCompute is Retrieve, except that a miss is filled by calling compute instead of the cache's
+// updater, for caches whose keys are computed by different logic at different call sites. The result
+// is stored like any other, and later refreshed by the cache's updater if it's still being read at
+// expiry. Concurrent misses on the same key share one call, whether they come from GetOrCompute or
+// Retrieve, so compute may not run at all if another fill of key is already in flight.
+func (c *Cache) GetOrCompute(key string, compute func() interface{}) interface{} {
+	cached, _, _ := c.retrieve(context.Background(), key, func(context.Context, string) (interface{}, time.Duration, error) {
+		return compute(), 0, nil
+	})
+
+	return c.copyOut(cached.value)
+}
+
+// RetrieveCtx is Retrieve, bounded by ctx. If ctx is done before or during the updater call,
+// RetrieveCtx returns ctx.Err() and nothing is stored. The cache isn't locked while the updater runs,
+// so a slow lookup doesn't hold up other callers. Errors from the updater are returned as-is, and
+// using an imploded cache returns ErrCacheClosed.
+func (c *Cache) RetrieveCtx(ctx context.Context, key string) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	cached, _, err := c.retrieve(ctx, key, nil)
+	if failed, ok := cached.value.(cachedError); ok {
+		return nil, failed.err
+	}
+
+	return c.copyOut(cached.value), err
+}
+
+// RetrieveOrError is Retrieve, except that it returns the updater's error when filling key fails,
+// so callers of a CreateCacheE cache can tell a cached nil from a failed lookup. Nothing is stored on
+// failure. The error always wins over a stale value: when an expired entry's fill fails, the stale
+// value isn't returned, and the entry is left as it was. Stale values which are served without
+// blocking on a fill, see WithStaleWhileRevalidate, come with a nil error even if their background
+// refresh goes on to fail. Using an imploded cache returns ErrCacheClosed.
+func (c *Cache) RetrieveOrError(key string) (interface{}, error) {
+	return c.RetrieveCtx(context.Background(), key)
+}
+
+// RetrieveIfPresent is Retrieve without the updater: a present and unexpired entry is returned and
+// marked as accessed, exactly as Retrieve would, so it's kept warm by the cleaner, but absent and
+// expired keys are reported as not ok, rather than filled. Use it for low-priority code paths which
+// should benefit from warm data without causing backend load themselves.
+func (c *Cache) RetrieveIfPresent(key string) (interface{}, bool) {
+	key = c.storedKey(key)
+
+	c.mu.RLock()
+	cached, isCacheHit := c.data[key]
+	c.mu.RUnlock()
+
+	// a cached error is a failed lookup, not a value which is present
+	if isCacheHit && isCachedError(cached.value) {
+		return nil, false
+	}
+
+	if isCacheHit && c.readHit(cached) && cached.expiresAt.After(c.clock.Now()) {
+		c.countHits(1)
+		return c.copyOut(cached.value), true
 	}
 
 	c.mu.Lock()
-	c.data = nil
-	c.updater = nil
-	c.expireRate = -1
-	p.removeCache(c)
+	entry, ok := c.data[key]
+
+	n := c.clock.Now()
+	if !ok || !entry.expiresAt.After(n) || c.tooOld(entry, n) || isCachedError(entry.value) {
+		c.mu.Unlock()
+		return nil, false
+	}
+
+	entry.wasAccessedInInterval = true
+	if c.sliding && !entry.pinned() {
+		entry.expiresAt = c.expiresFrom(n, entry.value, 0)
+	}
+
+	evicted := c.touchLocked(key, &entry)
+	c.data[key] = entry
 	c.mu.Unlock()
+
+	c.notifyEvicted(evicted)
+	c.countHits(1)
+
+	return c.copyOut(entry.value), true
 }
 
-// called from scrubber in pool.go
-func (c *Cache) processExpired() {
-	// Faster to acquire the write lock throughout the delete process than
-	// to acquire locks individually for each delete
+// RetrieveOrDefault is RetrieveIfPresent with a fallback, for lookups where a sensible default beats
+// waiting on the updater, eg: feature flags. A present and unexpired value is returned, and marked as
+// accessed, otherwise def is returned at once. With fill set, a miss also starts filling key in the
+// background, through the updater and shared with concurrent fills of key, so that later calls hit.
+// Without it, RetrieveOrDefault never calls the updater.
+func (c *Cache) RetrieveOrDefault(key string, def interface{}, fill bool) interface{} {
+	if v, ok := c.RetrieveIfPresent(key); ok {
+		return v
+	}
+
+	if fill {
+		c.flights.doAsync(key, func() (expirable, error) {
+			return c.fillEntry(context.Background(), key, nil, true)
+		})
+	}
+
+	return def
+}
+
+// Peek reports the cached value for key, if it is present and unexpired, without calling the
+// updater and without marking the entry as accessed. Peeking never affects whether the cleaner
+// refreshes or evicts an entry, which makes it suitable for debug endpoints and warm-up decisions.
+func (c *Cache) Peek(key string) (value interface{}, ok bool) {
+	key = c.storedKey(key)
+
+	c.mu.RLock()
+	cached, isCacheHit := c.data[key]
+	c.mu.RUnlock()
+
+	if !isCacheHit || !cached.expiresAt.After(c.clock.Now()) || isCachedError(cached.value) {
+		return nil, false
+	}
+
+	return c.copyOut(cached.value), true
+}
+
+// TTL reports how long the entry for key has left before it expires, and whether key is present
+// at all. A present but expired entry, which the cleaner has yet to scrub or refresh, has a TTL of
+// zero or less, which distinguishes stale from missing. Like Peek, TTL doesn't mark the entry as
+// accessed.
+func (c *Cache) TTL(key string) (time.Duration, bool) {
+	key = c.storedKey(key)
+
+	c.mu.RLock()
+	cached, isCacheHit := c.data[key]
+	c.mu.RUnlock()
+
+	if !isCacheHit {
+		return 0, false
+	}
+
+	return cached.expiresAt.Sub(c.clock.Now()), true
+}
+
+// RetrieveWithTTL is Retrieve, also returning how long the returned value has left before it
+// expires. Both come from the same entry, so unlike Retrieve followed by TTL, the TTL can't belong to
+// a value refreshed in the meantime. A freshly filled value has a TTL of (about) the full
+// expireRate, and a stale value, served under WithServeStale or WithStaleWhileRevalidate, one of zero
+// or less. An imploded cache, or an error cached by WithErrorCaching, returns nil and zero.
+func (c *Cache) RetrieveWithTTL(key string) (value interface{}, ttl time.Duration) {
+	cached, _, err := c.retrieve(context.Background(), key, nil)
+	if err != nil || isCachedError(cached.value) {
+		return nil, 0
+	}
+
+	return c.copyOut(cached.value), cached.expiresAt.Sub(c.clock.Now())
+}
+
+// Touch pushes the expiry of the entry for key back to a full expireRate from now, unless it's
+// pinned, and marks it as accessed, as if it had just been retrieved, but without the cost of
+// Retrieve and without ever calling the updater. Use it to keep keys alive on signals from
+// elsewhere, eg: a session heartbeat.
+// Touch reports whether key was present; it's a no-op for absent keys and on an imploded cache.
+func (c *Cache) Touch(key string) bool {
+	key = c.storedKey(key)
+
 	c.mu.Lock()
-	var wg sync.WaitGroup
-	n := time.Now()
+	entry, ok := c.data[key]
+	if !ok {
+		c.mu.Unlock()
+		return false
+	}
+
+	if !entry.pinned() {
+		entry.expiresAt = c.expiresFrom(c.clock.Now(), entry.value, 0)
+	}
+	entry.wasAccessedInInterval = true
+
+	evicted := c.touchLocked(key, &entry)
+	c.data[key] = entry
+	c.mu.Unlock()
+
+	c.notifyEvicted(evicted)
+
+	return true
+}
+
+// Len returns the number of entries currently held by the cache. The count includes entries which
+// have expired but not yet been scrubbed by the cleaner; see LenUnexpired. An imploded cache has a
+// Len of 0.
+func (c *Cache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return len(c.data)
+}
+
+// LenUnexpired is Len, minus the entries which have already expired. It walks every entry, so
+// unlike Len it costs O(n) under the read lock.
+func (c *Cache) LenUnexpired() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	n := c.clock.Now()
+	count := 0
+	for _, entry := range c.data {
+		if entry.expiresAt.After(n) {
+			count++
+		}
+	}
+
+	return count
+}
+
+// Keys returns a snapshot of every key currently held by the cache, including expired entries
+// the cleaner has yet to scrub. The whole map is walked under the read lock, which blocks writers
+// (misses, Delete, the cleaner) for the duration, so keep it to debug and admin paths on big caches.
+func (c *Cache) Keys() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	keys := make([]string, 0, len(c.data))
+	for key := range c.data {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+// Range calls f for each entry in the cache, in no particular order, stopping early if f returns
+// false. Ranging doesn't mark entries as accessed, so it never keeps an entry alive. The read lock is
+// held throughout, so f must not call back into any method of the cache which writes to it.
+func (c *Cache) Range(f func(key string, value interface{}) bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 
 	for key, entry := range c.data {
-		if !entry.expiresAt.Before(n) {
-			continue
+		value := entry.value
+		if isAbsent(value) {
+			value = nil
 		}
 
-		if !entry.wasAccessedInInterval {
-			delete(c.data, key)
-			continue
+		if !f(key, value) {
+			return
 		}
+	}
+}
 
-		wg.Add(1)
-		// TODO: Chunk these ops so only a few are launched in goroutines at a time?
-		go func(k string, n time.Time) {
-			c.data[k] = expirable{
-				value:     c.updater(k),
-				expiresAt: n.Add(c.expireRate),
-			}
-			wg.Done()
-		}(key, n)
+// ForEach is Range for big caches, which doesn't block writers for the whole walk: the keys are
+// snapshotted like Keys does, then each is looked up under a read lock of its own, with f called
+// unlocked, so f may call back into the cache. The price is consistency, entries stored after the
+// snapshot aren't visited, and those removed since are visited with ok false and a nil value. Like
+// Range, ForEach doesn't mark entries as accessed.
+func (c *Cache) ForEach(f func(key string, value interface{}, ok bool)) {
+	for _, key := range c.Keys() {
+		c.mu.RLock()
+		entry, ok := c.data[key]
+		c.mu.RUnlock()
+
+		value := entry.value
+		if isAbsent(value) {
+			value = nil
+		}
+
+		f(key, value, ok)
+	}
+}
+
+// Set stores value under key without calling the updater, eg: for a value pushed from a message
+// queue, so the next Retrieve of key is a hit. The entry expires expireRate from now and starts out
+// not accessed, so the cleaner evicts it at expiry unless it's read in the meantime, in which case
+// the cleaner refreshes it through the updater like any other entry. Set on an imploded cache is a
+// no-op.
+func (c *Cache) Set(key string, value interface{}) {
+	c.validate(key, value)
+	key = c.storedKey(key)
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return
+	}
+
+	n := c.clock.Now()
+	entry := c.data[key]
+	entry.value = value
+	entry.expiresAt = c.expiresFrom(n, value, 0)
+	entry.createdAt = n
+	entry.version = c.nextVersionLocked()
+	entry.wasAccessedInInterval = false
+
+	evicted := c.touchLocked(key, &entry)
+	c.data[key] = entry
+	c.mu.Unlock()
+
+	c.notifyEvicted(evicted)
+}
+
+// Delete drops the entry for key, if there is one, so the next Retrieve of key is a cold miss which
+// calls the updater. Deleting an absent key, or from an imploded cache, is a no-op. Explicit deletes
+// are not counted as Evictions in Stats, which only tracks entries pruned by the cache itself.
+func (c *Cache) Delete(key string) {
+	key = c.storedKey(key)
+
+	c.mu.Lock()
+	entry, ok := c.data[key]
+	if ok {
+		c.removeLocked(key, entry)
 	}
-	wg.Wait()
 	c.mu.Unlock()
+
+	c.dropSecondary(key)
+
+	if ok {
+		c.evicted(key, entry.value)
+	}
 }
 
-func (c *Cache) retrieveEntry(key string, entry expirable) expirable {
+// DeletePrefix drops every entry whose key starts with prefix, eg: all of a user's entries under
+// keys like "user:123:", and returns how many it dropped. It walks the whole map under the write
+// lock, so it costs O(n) in the size of the cache; keep it to invalidation and admin paths rather
+// than hot loops. Like Delete, it calls OnEvict for each entry but doesn't count them as Evictions,
+// and it's a no-op on an imploded cache.
+func (c *Cache) DeletePrefix(prefix string) int {
+	var deleted []removal
+
 	c.mu.Lock()
+	for key, entry := range c.data {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
 
-	if entry.wasAccessedInInterval == false {
-		entry.expiresAt = time.Now().Add(c.expireRate)
-		entry.value = c.updater(key)
+		c.removeLocked(key, entry)
+		deleted = append(deleted, removal{key: key, value: entry.value})
 	}
+	c.floorSecondaryLocked(prefix)
+	c.mu.Unlock()
 
-	entry.wasAccessedInInterval = true
-	c.data[key] = entry
+	for _, r := range deleted {
+		c.evicted(r.key, r.value)
+	}
+
+	return len(deleted)
+}
+
+// Flush drops every entry at once, eg: after a config reload changes how values are computed, so
+// every key's next Retrieve is a miss. Unlike Implode, the cache stays registered with the cleaner
+// and fully usable. OnEvict, if set, is called for each dropped entry; like Delete, none of them are
+// counted as Evictions in Stats.
+func (c *Cache) Flush() {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return
+	}
+
+	old := c.data
+	c.data = make(map[string]expirable, c.capacity)
+	if c.lru != nil {
+		c.lru = list.New()
+	}
+	c.usedBytes.Store(0)
+	c.floorSecondaryLocked("")
 	c.mu.Unlock()
 
-	return entry
+	if c.onEvict != nil {
+		for key, entry := range old {
+			c.evicted(key, entry.value)
+		}
+	}
+}
+
+// SetUpdater swaps the cache's updater, eg: on failover to a replica with a different client. The
+// entries already cached stay put, and the new updater is used from the next miss or refresh on.
+// Like CreateCache, SetUpdater panics on a nil updater. It's a no-op on an imploded cache.
+func (c *Cache) SetUpdater(updater EntryUpdater) {
+	if updater == nil {
+		panic("the updater-func must be a non-nil reference to an EntryUpdater")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return
+	}
+
+	c.updater = func(_ context.Context, key string) (interface{}, time.Duration, error) {
+		return updater(key), 0, nil
+	}
+	c.batchFill = nil
+}
+
+// SetExpireRate changes how long entries are cached for, eg: lengthening it during an incident to
+// shed backend load. Entries already cached keep their expiry, and the new rate is used from the next
+// fill or refresh on. Like CreateCache, SetExpireRate panics on a non-positive rate. It's a no-op on
+// an imploded cache.
+func (c *Cache) SetExpireRate(d time.Duration) {
+	if d <= 0 {
+		panic("the expireRate must be positive, entries would be born expired")
+	}
+
+	// every read of expireRate, the cleaner's included, is made with the lock held
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return
+	}
+
+	c.expireRate = d
+}
+
+// IsClosed reports whether the cache has been imploded
+func (c *Cache) IsClosed() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.closed
+}
+
+// Implode inactivates the cache from the eager cleaning and eager updating processes, and releases
+// its entries. Once Implode is called the cache is closed: Retrieve returns nil, Peek reports every
+// key as absent, Len is 0 and Delete is a no-op, so a goroutine racing a shutdown can't crash.
+//
+// Implode is idempotent, so shutdown paths which may run twice can call it freely. It reports
+// whether this call is the one which closed the cache, false for a nil or already imploded cache.
+func (c *Cache) Implode() bool {
+	return c.ImplodeWith(nil)
+}
+
+// ImplodeWith is Implode, which then hands every entry the cache still held to cleanup, eg: to close
+// the files or connections the values wrap, rather than leaking them. cleanup is called once the
+// cache is closed and unlocked, so it may block, and it sees each entry once, including expired
+// entries the cleaner had yet to scrub. Only the call which closes the cache calls cleanup, and a nil
+// cleanup is allowed, making ImplodeWith(nil) the same as Implode.
+func (c *Cache) ImplodeWith(cleanup func(key string, value interface{})) bool {
+	if c == nil {
+		return false
+	}
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return false
+	}
+
+	data := c.data
+
+	c.closed = true
+	c.data = nil
+	c.lru = nil
+	c.usedBytes.Store(0)
+	c.updater = nil
+	c.expireRate = -1
+	p.removeCache(c.cacheState)
+	c.mu.Unlock()
+
+	c.events.closeAll()
+
+	if cleanup != nil {
+		for key, entry := range data {
+			if !isAbsent(entry.value) {
+				cleanup(key, entry.value)
+			}
+		}
+	}
+
+	return true
+}
+
+// RunScrubNow scrubs the cache right away, exactly as the cleaner would, and returns once its
+// refreshes are done, so that tests can advance a cache deterministically, instead of sleeping
+// through the clean rate. It's safe to call while the cleaner is running, as a refresh is only stored
+// if its entry hasn't been replaced meanwhile, and it doesn't change when the cleaner next scrubs the
+// cache.
+func (c *Cache) RunScrubNow() {
+	c.processExpired()
+}
+
+// called from scrubber in pool.go
+func (c *Cache) processExpired() {
+	// Faster to acquire the write lock throughout the delete process than
+	// to acquire locks individually for each delete
+	c.mu.Lock()
+	// the scrubber may have picked the cache just before it was imploded
+	if c.closed {
+		c.mu.Unlock()
+		return
+	}
+
+	n := c.clock.Now()
+	updater := c.updater
+
+	var (
+		stale   []refresh
+		evicted []removal
+		expired int
+	)
+	if c.logger != nil {
+		c.logger.Log(LogDebug, "scrub started", "cache", c.name, "entries", len(c.data))
+	}
+	for key, entry := range c.data {
+		if c.evictionPolicy != nil && !entry.pinned() {
+			switch c.evictionPolicy(key, entryInfo(entry, n)) {
+			case DecisionEvict:
+				evicted = c.evictLocked(key, entry, evicted)
+				expired++
+			case DecisionRefresh:
+				if !c.refreshPaused && c.refreshDue(entry, n) {
+					stale = append(stale, refresh{key: key, entry: entry})
+				}
+			}
+			continue
+		}
+
+		if c.tooOld(entry, n) {
+			evicted = c.evictLocked(key, entry, evicted)
+			expired++
+			continue
+		}
+
+		if !entry.expiresAt.Before(n) {
+			// with WithRefreshAhead, hot entries are refreshed while they're still valid
+			if entry.wasAccessedInInterval && c.eager() && !c.refreshPaused && c.refreshDue(entry, n) &&
+				entry.expiresAt.Sub(n) < c.refreshAhead && !isCachedError(entry.value) {
+				stale = append(stale, refresh{key: key, entry: entry})
+			}
+			continue
+		}
+
+		if !entry.wasAccessedInInterval || !c.eager() || isCachedError(entry.value) {
+			evicted = c.evictLocked(key, entry, evicted)
+			expired++
+			continue
+		}
+
+		// while refreshes are paused, or too recent, accessed entries keep serving their last value
+		if c.refreshPaused || !c.refreshDue(entry, n) {
+			continue
+		}
+
+		stale = append(stale, refresh{key: key, entry: entry})
+	}
+
+	// The lock is released while the updater runs, so that a slow refresh doesn't hold up the
+	// readers of every other key. The stale entries are served as they are in the meantime.
+	c.mu.Unlock()
+
+	ctx, cancel := c.refreshContext()
+	c.refill(ctx, updater, stale)
+	cancel()
+
+	c.mu.Lock()
+	for i := range stale {
+		r := &stale[i]
+
+		// entries which were Set, refilled or dropped while the updater ran keep what happened to them,
+		// and nothing is stored if the cache was imploded meanwhile, its data is nil
+		entry, ok := c.data[r.key]
+		if c.closed || !ok || entry.version != r.entry.version {
+			r.ok = false
+			continue
+		}
+
+		if r.timedOut && c.evictOnRefreshTimeout {
+			evicted = c.evictLocked(r.key, entry, evicted)
+			expired++
+			continue
+		}
+
+		// a failed refresh keeps serving the last good value, and is retried once the backoff is up
+		if !r.ok {
+			if retry := n.Add(c.refreshBackoff); c.refreshBackoff > 0 && entry.expiresAt.Before(retry) {
+				entry.expiresAt = retry
+				c.data[r.key] = entry
+			}
+			continue
+		}
+
+		// only the value, expiry and access are replaced, the entry may have been read meanwhile. An
+		// unchanged value is kept, along with its version.
+		if !r.unchanged {
+			entry.value = r.entry.value
+			entry.version = c.nextVersionLocked()
+		}
+		entry.wasAccessedInInterval = false
+		entry.expiresAt = c.expiresFrom(n, entry.value, r.ttl)
+		entry.lastRefresh = n
+		r.entry = entry
+
+		c.resizeLocked(&entry)
+		c.data[r.key] = entry
+		evicted = c.trimLocked(entry.elem, 0, evicted)
+	}
+	c.mu.Unlock()
+
+	refreshed := 0
+	for _, r := range stale {
+		if r.ok {
+			refreshed++
+			if c.logger != nil {
+				c.logger.Log(LogDebug, "entry refreshed", "cache", c.name, "key", r.key)
+			}
+			if !r.unchanged {
+				c.events.publish(EventRefresh, r.key)
+			}
+		}
+	}
+	if c.logger != nil {
+		c.logger.Log(LogDebug, "scrub finished", "cache", c.name, "evicted", expired, "refreshed", refreshed,
+			"failed", len(stale)-refreshed)
+	}
+
+	c.notifyEvicted(evicted)
+	if c.onRefresh != nil {
+		for _, r := range stale {
+			if r.ok && !r.unchanged {
+				c.onRefresh(r.key, valueOf(r.old), valueOf(r.entry.value))
+			}
+		}
+	}
+}
+
+// refreshContext returns the context a batch of background refreshes runs under, bounded by
+// WithRefreshTimeout if it's set
+func (c *Cache) refreshContext() (context.Context, context.CancelFunc) {
+	if c.refreshTimeout <= 0 {
+		return context.Background(), func() {}
+	}
+
+	return context.WithTimeout(context.WithValue(context.Background(), refreshContextKey{}, true), c.refreshTimeout)
+}
+
+// refill calls updater for each of stale, concurrently under refreshLimit and bounded by ctx, and
+// replaces the entry of every one whose call succeeded with a fresh one. Each goroutine only writes
+// its own slot of stale, so the caller is left to store the entries, once it has set their expiry
+// from ttl under the lock.
+func (c *Cache) refill(ctx context.Context, updater loadFunc, stale []refresh) {
+	c.stats.refreshes.Add(uint64(len(stale)))
+	boundedRun(c.refreshLimit(), len(stale), func(i int) {
+		r := &stale[i]
+
+		start := time.Now()
+		v, ttl, err := c.loadWithin(ctx, updater, r.key)
+		c.metrics.RecordRefreshDuration(c.name, time.Since(start))
+		if err != nil {
+			r.timedOut = errors.Is(err, context.DeadlineExceeded)
+			return
+		}
+
+		// validate would panic on this goroutine, where nothing recovers, so without a handler an
+		// invalid value fails the refresh instead
+		if err := c.invalid(r.key, v); err != nil {
+			if c.onInvalid == nil {
+				if c.logger != nil {
+					c.logger.Log(LogError, "refresh returned an invalid value", "cache", c.name, "key", r.key, "err", err)
+				}
+				return
+			}
+			c.onInvalid(r.key, err)
+		}
+
+		// The refreshed entry starts its interval unaccessed, so it's only refreshed again if it's
+		// read before its next expiry. Otherwise the next pass to find it expired evicts it.
+		r.old = r.entry.value
+		r.ttl = ttl
+		r.unchanged = c.equalityFunc != nil && !isAbsent(r.old) && !isAbsent(v) && c.equalityFunc(r.old, v)
+		r.entry = expirable{
+			value:                 v,
+			wasAccessedInInterval: false,
+			createdAt:             r.entry.createdAt,
+			elem:                  r.entry.elem,
+			size:                  r.entry.size,
+			version:               r.entry.version,
+		}
+		r.ok = true
+	})
+}
+
+// loadWithin is safeLoad, except that it gives up on load once ctx is done, even if load ignores
+// ctx. An abandoned load carries on in its own goroutine, and its result is discarded, it's the
+// abandonment which the circuit breaker counts.
+func (c *Cache) loadWithin(ctx context.Context, load loadFunc, key string) (interface{}, time.Duration, error) {
+	if ctx.Done() == nil {
+		return c.safeLoad(ctx, load, key)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	if !c.breaker.allow(c.clock.Now()) {
+		return nil, 0, ErrCircuitOpen
+	}
+
+	type result struct {
+		v   interface{}
+		ttl time.Duration
+		err error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		v, ttl, err := c.recoveredLoad(ctx, load, key)
+		done <- result{v: v, ttl: ttl, err: err}
+	}()
+
+	var r result
+	select {
+	case r = <-done:
+	case <-ctx.Done():
+		r.err = ctx.Err()
+	}
+
+	if !callerDone(ctx) {
+		c.breaker.record(c.clock.Now(), r.err)
+	}
+
+	return r.v, r.ttl, r.err
+}
+
+// RefreshMatching re-fills every entry whose key matches pred through the updater, eg: all of a
+// tenant's entries after its config changed, and returns how many it refreshed. Unlike Flush, the
+// old values are served until their replacements are stored, and unlike Delete, the keys are refilled
+// right away. The updater calls are made without the cache locked, concurrently under the limit of
+// WithRefreshConcurrency, and count as Refreshes in Stats. Entries whose updater call fails are left
+// as they were, as are those deleted or replaced, eg: by Set, while being refreshed, which aren't
+// counted in the result. pred is called with the cache locked, so it must not use the cache.
+func (c *Cache) RefreshMatching(pred func(key string) bool) int {
+	c.mu.RLock()
+	if c.closed || c.keyHasher != nil {
+		c.mu.RUnlock()
+		return 0
+	}
+
+	updater := c.updater
+
+	var stale []refresh
+	for key, entry := range c.data {
+		if pred(key) {
+			stale = append(stale, refresh{key: key, entry: entry})
+		}
+	}
+	c.mu.RUnlock()
+
+	ctx, cancel := c.refreshContext()
+	c.refill(ctx, updater, stale)
+	cancel()
+
+	var evicted []removal
+
+	c.mu.Lock()
+	n := c.clock.Now()
+	for i := range stale {
+		r := &stale[i]
+		if !r.ok {
+			continue
+		}
+
+		// as in processExpired, entries which were Set, refilled or dropped while the updater ran keep
+		// what happened to them
+		entry, ok := c.data[r.key]
+		if !ok || c.closed || entry.version != r.entry.version {
+			r.ok = false
+			continue
+		}
+
+		// only the value and expiry are replaced, the entry may have been read since it was copied
+		entry.value = r.entry.value
+		entry.expiresAt = c.expiresFrom(n, entry.value, r.ttl)
+		entry.version = c.nextVersionLocked()
+		entry.lastRefresh = n
+		evicted = append(evicted, c.touchLocked(r.key, &entry)...)
+		c.data[r.key] = entry
+	}
+	c.mu.Unlock()
+
+	c.notifyEvicted(evicted)
+
+	refreshed := 0
+	for _, r := range stale {
+		if !r.ok {
+			continue
+		}
+
+		refreshed++
+		c.events.publish(EventRefresh, r.key)
+		if c.onRefresh != nil {
+			c.onRefresh(r.key, valueOf(r.old), valueOf(r.entry.value))
+		}
+	}
+
+	return refreshed
+}
+
+// retrieve is the common implementation of the Retrieve family. Hits on entries already marked as
+// accessed are served under the read lock alone, anything else goes through retrieveEntry. On error,
+// the returned entry is always the zero expirable.
+//
+// The read lock path is the hot one, it must not allocate: on a Retrieve hit it costs one RLock, one
+// map lookup and the hit counters. The value is returned as stored, callers hand it out through
+// copyOut.
+func (c *Cache) retrieve(ctx context.Context, key string, load loadFunc) (expirable, Status, error) {
+	c.mu.RLock()
+	cached, isCacheHit := c.data[c.storedKey(key)]
+	closed := c.closed
+	c.mu.RUnlock()
+
+	if closed {
+		return expirable{}, StatusMiss, ErrCacheClosed
+	}
+
+	if isCacheHit && c.readHit(cached) {
+		c.countHits(1)
+		return cached, StatusHit, nil
+	}
+
+	cached, status, err := c.retrieveEntry(ctx, key, load)
+	if err != nil {
+		return expirable{}, status, err
+	}
+
+	return cached, status, nil
+}
+
+// retrieveEntry handles every Retrieve which can't be served under the read lock alone. An entry
+// which is present and unexpired only has to be marked as accessed, anything else is filled through
+// the updater, or by load when it isn't nil. Concurrent misses on the same key share a single call.
+func (c *Cache) retrieveEntry(ctx context.Context, key string, load loadFunc) (expirable, Status, error) {
+	sk := c.storedKey(key)
+
+	c.mu.Lock()
+	entry, ok := c.data[sk]
+
+	if c.closed {
+		c.mu.Unlock()
+		return entry, StatusMiss, ErrCacheClosed
+	}
+
+	n := c.clock.Now()
+	if ok && c.servable(entry, n) {
+		if !entry.wasAccessedInInterval || c.sliding {
+			entry.wasAccessedInInterval = true
+			if c.sliding && !entry.pinned() {
+				entry.expiresAt = c.expiresFrom(n, entry.value, 0)
+			}
+
+			c.touchLocked(sk, &entry)
+			c.data[sk] = entry
+		}
+		c.mu.Unlock()
+
+		c.countHits(1)
+		return entry, StatusHit, nil
+	}
+	c.mu.Unlock()
+
+	// Serve the stale value, and refresh it in the background. The entry is only marked as accessed
+	// once the refresh stores its replacement, otherwise the fill would consider it still servable.
+	if ok && c.revalidateWindow > 0 && n.Sub(entry.expiresAt) <= c.revalidateWindow && !c.tooOld(entry, n) &&
+		!isCachedError(entry.value) {
+		c.flights.doAsync(key, func() (expirable, error) {
+			return c.fillEntry(context.Background(), key, load, true)
+		})
+
+		c.countHits(1)
+		return entry, StatusStale, nil
+	}
+
+	// Serve the stale value as-is, marking it as accessed so the cleaner's next pass refreshes it
+	if ok && c.maxStaleness > 0 && n.Sub(entry.expiresAt) <= c.maxStaleness && !c.tooOld(entry, n) &&
+		!isCachedError(entry.value) {
+		c.mu.Lock()
+		if current, present := c.data[sk]; present {
+			current.wasAccessedInInterval = true
+			c.data[sk] = current
+		}
+		c.mu.Unlock()
+
+		c.countHits(1)
+		return entry, StatusStale, nil
+	}
+
+	c.countMisses(1)
+	if c.logger != nil {
+		c.logger.Log(LogDebug, "cache miss", "cache", c.name, "key", key)
+	}
+	c.events.publish(EventMiss, key)
+
+	status := StatusMiss
+	if ok {
+		status = StatusExpiredRefreshed
+	}
+
+	entry, err := c.flights.do(ctx, key, func() (expirable, error) {
+		return c.fillEntry(ctx, key, load, true)
+	})
+
+	return entry, status, err
+}
+
+// fillEntry calls load, or the updater when load is nil, for key, unless another goroutine already
+// filled it, and stores the result, marking it as accessed if access is set. The lock is released
+// for the duration of the call so unrelated keys aren't blocked behind it.
+func (c *Cache) fillEntry(ctx context.Context, key string, load loadFunc, access bool) (expirable, error) {
+	sk := c.storedKey(key)
+
+	// re-read, another goroutine may have filled the key since our RLock
+	c.mu.RLock()
+	entry, ok := c.data[sk]
+	updater := c.updater
+	batch := c.batchFill
+	closed := c.closed
+	c.mu.RUnlock()
+
+	if closed {
+		return entry, ErrCacheClosed
+	}
+
+	if ok && c.servable(entry, c.clock.Now()) {
+		return entry, nil
+	}
+
+	// related holds the values of other keys, which a CreateCacheBatchFill updater returned along
+	// with key's
+	var related map[string]interface{}
+	if load == nil {
+		load = updater
+		if batch != nil {
+			load = func(_ context.Context, key string) (interface{}, time.Duration, error) {
+				related = batch(key)
+				return related[key], 0, nil
+			}
+		}
+	}
+
+	// an entry spilled to the secondary store is reloaded from there, instead of through the updater
+	v, ok := c.fromSecondary(sk)
+
+	var (
+		ttl time.Duration
+		err error
+	)
+	if !ok {
+		v, ttl, err = c.safeLoad(ctx, load, key)
+		if err == nil {
+			err = ctx.Err()
+		}
+	}
+
+	if err != nil && c.cachesError(err) {
+		v, ttl, err = cachedError{err: err}, 0, nil
+	}
+
+	if err != nil {
+		return entry, err
+	}
+
+	c.validate(key, v)
+	for k, value := range related {
+		if k != key {
+			c.validate(k, value)
+		}
+	}
+
+	c.mu.Lock()
+
+	n := c.clock.Now()
+	var evicted []removal
+	if !c.closed {
+		// stored ahead of key, so that filling them can't push key itself out of a size limit
+		for k, value := range related {
+			if k == key {
+				continue
+			}
+
+			k = c.storedKey(k)
+			e := c.data[k]
+			e.value = value
+			e.expiresAt = c.expiresFrom(n, value, 0)
+			e.createdAt = n
+			e.version = c.nextVersionLocked()
+			e.wasAccessedInInterval = false
+			evicted = append(evicted, c.touchLocked(k, &e)...)
+			c.data[k] = e
+		}
+	}
+
+	entry = c.data[sk]
+	entry.expiresAt = c.expiresFrom(n, v, ttl)
+	entry.createdAt = n
+	entry.value = v
+	entry.wasAccessedInInterval = entry.wasAccessedInInterval || access
+
+	// the cache was imploded while the updater ran, hand the value back but don't store it
+	if c.closed {
+		c.mu.Unlock()
+		return entry, nil
+	}
+
+	entry.version = c.nextVersionLocked()
+	evicted = append(evicted, c.touchLocked(sk, &entry)...)
+	c.data[sk] = entry
+	c.mu.Unlock()
+
+	c.notifyEvicted(evicted)
+
+	return entry, nil
+}
+
+// servable reports whether Retrieve may return entry without calling the updater. Besides unexpired
+// entries, that includes the expired but accessed entries which are awaiting their eager refresh.
+func (c *Cache) servable(entry expirable, n time.Time) bool {
+	if c.tooOld(entry, n) {
+		return false
+	}
+
+	if entry.expiresAt.After(n) {
+		return true
+	}
+
+	return entry.wasAccessedInInterval && c.eager() && !isCachedError(entry.value)
+}
+
+// readHit reports whether Retrieve may return entry under the read lock alone, which it can when
+// entry is servable and already marked as accessed, or merely unexpired WithoutAccessTracking.
+// Sliding entries never are, since every read has to push their expiry back.
+func (c *Cache) readHit(entry expirable) bool {
+	if c.sliding && !entry.pinned() {
+		return false
+	}
+
+	if c.maxAge > 0 && c.tooOld(entry, c.clock.Now()) {
+		return false
+	}
+
+	if c.untracked {
+		return entry.expiresAt.After(c.clock.Now())
+	}
+
+	if !entry.wasAccessedInInterval {
+		return false
+	}
+
+	return (c.eager() && !isCachedError(entry.value)) || entry.expiresAt.After(c.clock.Now())
+}
+
+// refreshDue reports whether the cleaner may refresh entry at n, given WithMinRefreshInterval
+func (c *Cache) refreshDue(entry expirable, n time.Time) bool {
+	return c.minRefreshInterval <= 0 || n.Sub(entry.lastRefresh) >= c.minRefreshInterval
+}
+
+// tooOld reports whether entry was filled more than WithMaxAge ago, and so may neither be served nor
+// refreshed any longer. Pinned entries never are.
+func (c *Cache) tooOld(entry expirable, n time.Time) bool {
+	return c.maxAge > 0 && !entry.pinned() && n.Sub(entry.createdAt) > c.maxAge
+}
+
+// eager reports whether the cleaner refreshes expired entries which were accessed, instead of
+// evicting them. It doesn't with WithLazyEviction or WithoutAccessTracking, nor with
+// WithSlidingExpiration, where an entry can only expire by going unread, nor WithoutPool, where
+// there's no cleaner.
+func (c *Cache) eager() bool {
+	return !c.lazy && !c.sliding && !c.untracked && !c.unpooled && c.keyHasher == nil
+}
+
+// expiresFrom returns when an entry filled at n with value, and the updater-provided ttl, should
+// expire. Values which WithNegativeTTL considers not found get the negative TTL instead, and the
+// errors of WithErrorCaching its error TTL.
+func (c *Cache) expiresFrom(n time.Time, value interface{}, ttl time.Duration) time.Time {
+	if isCachedError(value) {
+		ttl = c.errorTTL
+	} else if c.isNotFound != nil && c.isNotFound(value) {
+		ttl = c.negativeTTL
+	}
+
+	if ttl <= 0 {
+		ttl = c.expireRate
+	}
+
+	if c.jitter > 0 {
+		c.rngMu.Lock()
+		ttl += time.Duration(c.rng.Float64() * c.jitter * float64(ttl))
+		c.rngMu.Unlock()
+	}
+
+	return n.Add(ttl)
+}
+
+// refreshLimit is the most updater calls a cleaning pass of the cache may have in flight at once
+func (c *Cache) refreshLimit() int {
+	if c.maxRefreshes > 0 {
+		return c.maxRefreshes
+	}
+
+	return runtime.GOMAXPROCS(0)
+}
+
+// boundedRun calls f(i) for every i in [0, n), in up to limit goroutines at a time, and returns once
+// every call has.
+func boundedRun(limit, n int, f func(i int)) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, limit)
+
+	for i := 0; i < n; i++ {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			f(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// copyOut returns v as it should be handed to a caller: nil for a known-absent value, see
+// CreateCacheFound, and cloned when WithCopyOnRetrieve is in use
+func (c *Cache) copyOut(v interface{}) interface{} {
+	if isAbsent(v) {
+		return nil
+	}
+
+	if c.copyOnRetrieve == nil || v == nil {
+		return v
+	}
+
+	return c.copyOnRetrieve(v)
+}
+
+// evictLocked removes key as an eviction, counting it in Stats and adding it to evicted when there's
+// an OnEvict callback to notify. The caller must hold c.mu for writing.
+func (c *Cache) evictLocked(key string, entry expirable, evicted []removal) []removal {
+	c.removeLocked(key, entry)
+	c.stats.evictions.Add(1)
+	c.metrics.RecordEvict(c.name)
+	if c.logger != nil {
+		c.logger.Log(LogDebug, "entry evicted", "cache", c.name, "key", key)
+	}
+	c.events.publish(EventEvict, key)
+
+	if c.secondary != nil {
+		c.noteSpillLocked(entry.version)
+	}
+
+	if c.onEvict != nil || c.secondary != nil {
+		evicted = append(evicted, removal{key: key, value: entry.value, version: entry.version})
+	}
+
+	return evicted
+}
+
+// notifyEvicted spills each of evicted to the secondary store, then calls the OnEvict callback for
+// it. It must be called without c.mu held, so the callback is free to use the cache, and the store
+// may be slow.
+func (c *Cache) notifyEvicted(evicted []removal) {
+	for _, r := range evicted {
+		c.toSecondary(r.key, r.value, r.version)
+		c.evicted(r.key, r.value)
+	}
+}
+
+// evicted calls the OnEvict callback, if there is one, for key leaving the cache with value. Keys
+// known to be absent, and cached errors, had no value to release, so they're skipped, as they are by
+// ImplodeWith. It must be called without c.mu held.
+func (c *Cache) evicted(key string, value interface{}) {
+	if c.onEvict != nil && !isAbsent(value) {
+		c.onEvict(key, value)
+	}
 }
@@ -0,0 +1,403 @@
+package eagercache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRefreshManyExpiringAccessedKeys(t *testing.T) {
+	const keys = 1000
+	clk := newFakeClock()
+	var calls atomic.Int64
+	c := CreateCache(time.Minute, func(key string) interface{} {
+		calls.Add(1)
+		return key
+	}, WithClock(clk), WithRefreshConcurrency(16))
+	defer c.Implode()
+
+	for i := 0; i < keys; i++ {
+		c.Retrieve(strconv.Itoa(i))
+	}
+
+	// every key expires at once, and the refreshes race readers of the same keys
+	clk.advance(2 * time.Minute)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < keys; i++ {
+				c.Retrieve(strconv.Itoa(i))
+			}
+		}()
+	}
+	c.RunScrubNow()
+	wg.Wait()
+
+	if n := c.Len(); n != keys {
+		t.Errorf("Len() = %d after the refresh, want all %d accessed keys kept", n, keys)
+	}
+
+	if n := calls.Load(); n != 2*keys {
+		t.Errorf("updater called %d times, want %d: one fill and one refresh per key", n, 2*keys)
+	}
+
+	for i := 0; i < keys; i++ {
+		if ttl, ok := c.TTL(strconv.Itoa(i)); !ok || ttl <= 0 {
+			t.Fatalf("TTL(%d) = %v, %v, want it refreshed", i, ttl, ok)
+		}
+	}
+}
+
+func TestRetrieveDuringImplode(t *testing.T) {
+	c := CreateCache(time.Minute, func(key string) interface{} { return key })
+
+	var wg sync.WaitGroup
+	var ops atomic.Int64
+	stop := make(chan struct{})
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				key := strconv.Itoa(g*1000 + i%100)
+				if v := c.Retrieve(key); v != nil && v != key {
+					t.Errorf("Retrieve(%s) = %v", key, v)
+				}
+				c.Peek(key)
+				c.Delete(key)
+				c.Len()
+				ops.Add(1)
+			}
+		}(g)
+	}
+
+	// implode in the middle of the readers' work
+	eventually(t, "the readers never got going", func() bool {
+		return ops.Load() >= 1000
+	})
+	c.Implode()
+	if !c.IsClosed() {
+		t.Error("IsClosed() = false after Implode")
+	}
+	close(stop)
+	wg.Wait()
+
+	if v := c.Retrieve("k"); v != nil {
+		t.Errorf("Retrieve on an imploded cache = %v, want nil", v)
+	}
+
+	if n := c.Len(); n != 0 {
+		t.Errorf("Len() = %d on an imploded cache, want 0", n)
+	}
+}
+
+func TestIdleKeyEvictedAfterRefresh(t *testing.T) {
+	clk := newFakeClock()
+	c := CreateCache(time.Minute, func(key string) interface{} { return key }, WithClock(clk))
+	defer c.Implode()
+
+	c.Retrieve("idle")
+	c.Retrieve("busy")
+
+	// the first scrub refreshes both, as they were read during the interval
+	clk.advance(2 * time.Minute)
+	c.RunScrubNow()
+	if n := c.Len(); n != 2 {
+		t.Fatalf("Len() = %d after the first scrub, want both keys refreshed", n)
+	}
+
+	// only busy is read again, so the second scrub evicts idle
+	c.Retrieve("busy")
+	clk.advance(2 * time.Minute)
+	c.RunScrubNow()
+
+	if _, ok := c.Peek("idle"); ok {
+		t.Error("idle survived a scrub cycle without being read")
+	}
+
+	if _, ok := c.Peek("busy"); !ok {
+		t.Error("busy was evicted, though it was read during the interval")
+	}
+}
+
+func TestNonPositiveExpireRate(t *testing.T) {
+	updater := func(key string) interface{} { return key }
+
+	for _, rate := range []time.Duration{0, -time.Second} {
+		if c, err := NewCache(rate, updater); !errors.Is(err, ErrInvalidExpireRate) || c != nil {
+			t.Errorf("NewCache(%v) = %v, %v, want ErrInvalidExpireRate", rate, c, err)
+		}
+
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("CreateCache(%v) didn't panic", rate)
+				}
+			}()
+			CreateCache(rate, updater)
+		}()
+	}
+
+	if _, err := NewCache(time.Minute, nil); !errors.Is(err, ErrNilUpdater) {
+		t.Errorf("NewCache with a nil updater = %v, want ErrNilUpdater", err)
+	}
+}
+
+// readHitCaches are the caches BenchmarkReadHits and BenchmarkFirstReads compare, with the default
+// access tracking and WithoutAccessTracking
+var readHitCaches = []struct {
+	name string
+	opts []Option
+}{
+	{"tracked", nil},
+	{"untracked", []Option{WithoutAccessTracking()}},
+}
+
+// BenchmarkReadHits reads hits from many goroutines at once, once every key has been read, so that
+// neither cache takes the write lock. Tracked entries which are already marked don't even need the
+// clock, while untracked ones check their expiry on every hit.
+func BenchmarkReadHits(b *testing.B) {
+	updater := func(key string) interface{} { return key }
+	for _, bc := range readHitCaches {
+		opts := bc.opts
+		b.Run(bc.name, func(b *testing.B) {
+			c := CreateCache(time.Minute, updater, opts...)
+			defer c.Implode()
+
+			for _, key := range benchKeys {
+				c.Retrieve(key)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for i := 0; pb.Next(); i++ {
+					c.Retrieve(benchKeys[i%len(benchKeys)])
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkFirstReads reads keys stored by Set, which start out unaccessed, so that with tracking
+// every read is a first read, which takes the write lock to mark the entry, as happens once per key
+// per cleaning interval. WithoutAccessTracking, they stay under the read lock.
+func BenchmarkFirstReads(b *testing.B) {
+	updater := func(key string) interface{} { return key }
+	for _, bc := range readHitCaches {
+		opts := bc.opts
+		b.Run(bc.name, func(b *testing.B) {
+			c := CreateCache(time.Minute, updater, opts...)
+			defer c.Implode()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if i%len(benchKeys) == 0 {
+					b.StopTimer()
+					for _, key := range benchKeys {
+						c.Set(key, key)
+					}
+					b.StartTimer()
+				}
+
+				c.Retrieve(benchKeys[i%len(benchKeys)])
+			}
+		})
+	}
+}
+
+func TestImplodeTwice(t *testing.T) {
+	var cleaned []string
+	c := CreateCache(time.Minute, func(key string) interface{} { return key })
+	c.Retrieve("k")
+
+	if !c.ImplodeWith(func(key string, value interface{}) { cleaned = append(cleaned, key) }) {
+		t.Fatal("the first Implode didn't report closing the cache")
+	}
+
+	if c.Implode() || c.ImplodeWith(func(key string, value interface{}) { cleaned = append(cleaned, key) }) {
+		t.Error("a second Implode reported closing the cache again")
+	}
+
+	if !c.IsClosed() || c.Len() != 0 || len(cleaned) != 1 {
+		t.Errorf("IsClosed() = %v, Len() = %d, cleaned %v, want closed, empty, and k cleaned up once",
+			c.IsClosed(), c.Len(), cleaned)
+	}
+
+	var nilCache *Cache
+	if nilCache.Implode() {
+		t.Error("Implode on a nil cache reported closing it")
+	}
+}
+
+func TestRetrieveHitDoesntAllocate(t *testing.T) {
+	c := CreateCache(time.Minute, func(key string) interface{} { return key })
+	defer c.Implode()
+	c.Retrieve("k")
+
+	if allocs := testing.AllocsPerRun(1000, func() { c.Retrieve("k") }); allocs != 0 {
+		t.Fatalf("a Retrieve hit allocates %v times, want 0", allocs)
+	}
+}
+
+func TestRetrieveMissDoesntAllocateForLogging(t *testing.T) {
+	v := new(int)
+	c := CreateCache(time.Minute, func(key string) interface{} { return v })
+	defer c.Implode()
+
+	// the miss and the delete's own allocations, without a logger nothing may be added to them to
+	// build log messages which would be thrown away
+	const budget = 2
+	if allocs := testing.AllocsPerRun(1000, func() {
+		c.Retrieve("k")
+		c.Delete("k")
+	}); allocs > budget {
+		t.Fatalf("a Retrieve miss and Delete allocate %v times without a logger, want at most %d", allocs, budget)
+	}
+}
+
+// BenchmarkRetrieveHit is the cost of the read path with every Retrieve a hit on an entry already
+// marked as accessed: a map lookup under the read lock, and no allocations
+func BenchmarkRetrieveHit(b *testing.B) {
+	c := CreateCache(time.Minute, func(key string) interface{} { return key })
+	defer c.Implode()
+	c.Retrieve("k")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Retrieve("k")
+	}
+}
+
+// BenchmarkReadsDuringSlowUpdater reads fast keys while the updater is busy with a slow one, whose
+// call takes 5ms, so that any lock held across that call would show up in the reads' ns/op. Misses
+// have long been filled without the lock, see fillEntry, and the cleaner's refreshes are too, since
+// processExpired releases the lock while they run; the two sub-benchmarks cover each path.
+func BenchmarkReadsDuringSlowUpdater(b *testing.B) {
+	newCache := func(clk Clock) *Cache {
+		return CreateCache(time.Minute, func(key string) interface{} {
+			if key == "slow" {
+				time.Sleep(5 * time.Millisecond)
+			}
+			return key
+		}, WithClock(clk))
+	}
+
+	// busy keeps the slow key's updater call running, one after the other, until stop is closed
+	run := func(b *testing.B, c *Cache, busy func()) {
+		for _, key := range benchKeys {
+			c.Pin(key, key)
+		}
+
+		stop, done := make(chan struct{}), make(chan struct{})
+		go func() {
+			defer close(done)
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					busy()
+				}
+			}
+		}()
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for i := 0; pb.Next(); i++ {
+				c.Retrieve(benchKeys[i%len(benchKeys)])
+			}
+		})
+		b.StopTimer()
+
+		close(stop)
+		<-done
+	}
+
+	b.Run("miss", func(b *testing.B) {
+		c := newCache(realClock{})
+		defer c.Implode()
+
+		run(b, c, func() {
+			c.Delete("slow")
+			c.Retrieve("slow")
+		})
+	})
+
+	b.Run("refresh", func(b *testing.B) {
+		clk := newFakeClock()
+		c := newCache(clk)
+		defer c.Implode()
+
+		run(b, c, func() {
+			c.Retrieve("slow")
+			clk.advance(2 * time.Minute)
+			c.RunScrubNow()
+		})
+	})
+}
+
+func TestErrCacheClosed(t *testing.T) {
+	c := CreateCacheE(time.Minute, func(key string) (interface{}, error) { return key, nil })
+	c.Retrieve("k")
+	c.Implode()
+
+	if _, err := c.RetrieveOrError("k"); !errors.Is(err, ErrCacheClosed) {
+		t.Errorf("RetrieveOrError = %v, want ErrCacheClosed", err)
+	}
+
+	if _, err := c.RetrieveCtx(context.Background(), "k"); !errors.Is(err, ErrCacheClosed) {
+		t.Errorf("RetrieveCtx = %v, want ErrCacheClosed", err)
+	}
+
+	if err := c.WarmUp([]string{"k"}); !errors.Is(err, ErrCacheClosed) {
+		t.Errorf("WarmUp = %v, want ErrCacheClosed", err)
+	}
+}
+
+func TestRefreshMatchingKeepsConcurrentSet(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	var calls atomic.Int32
+	c := CreateCache(time.Minute, func(key string) interface{} {
+		if calls.Add(1) == 2 {
+			close(entered)
+			<-release
+			return "refreshed"
+		}
+		return "filled"
+	}, WithoutPool())
+	c.Retrieve("k")
+
+	refreshed := make(chan int)
+	go func() {
+		refreshed <- c.RefreshMatching(func(key string) bool { return true })
+	}()
+	<-entered
+
+	c.Set("k", "set")
+	close(release)
+
+	if n := <-refreshed; n != 0 {
+		t.Errorf("RefreshMatching = %d, want 0, the only match was replaced meanwhile", n)
+	}
+
+	if v, _ := c.Peek("k"); v != "set" {
+		t.Errorf("Peek(k) = %v, want the value Set during the refresh, not the older load", v)
+	}
+}
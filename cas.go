@@ -0,0 +1,56 @@
+// Package eagercache provides interfaces for threadsafe, mildly-generic, in-memory caches
+// These caches are intended to maximize performance as much as possible by helping to
+// minimize expensive operations such as locking OS calls (File Reads, Network calls, et cetera)
+// License: MIT
+package eagercache
+
+import "reflect"
+
+// CompareAndSet stores newValue under key, as Set would, but only if key is present and its value is
+// still oldValue, and reports whether it swapped them. The comparison and the swap happen under the
+// cache's lock, so a concurrent refresh or Set is never clobbered, which supports optimistic
+// concurrency on top of the cache.
+//
+// Values are compared with Go's ==, so pointers only match when they point to the very same value,
+// not an equal copy of it, and values of uncomparable types, eg: maps or slices, never match.
+func (c *Cache) CompareAndSet(key string, oldValue, newValue interface{}) bool {
+	c.validate(key, newValue)
+	key = c.storedKey(key)
+
+	c.mu.Lock()
+	entry, ok := c.data[key]
+	if !ok || !equal(entry.value, oldValue) {
+		c.mu.Unlock()
+		return false
+	}
+
+	n := c.clock.Now()
+	entry.value = newValue
+	entry.expiresAt = c.expiresFrom(n, newValue, 0)
+	entry.createdAt = n
+	entry.version = c.nextVersionLocked()
+	entry.wasAccessedInInterval = false
+
+	evicted := c.touchLocked(key, &entry)
+	c.data[key] = entry
+	c.mu.Unlock()
+
+	c.notifyEvicted(evicted)
+
+	return true
+}
+
+// equal is a == b, except that it reports false for values of uncomparable types, instead of
+// panicking
+func equal(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	t := reflect.TypeOf(a)
+	if t != reflect.TypeOf(b) || !t.Comparable() {
+		return false
+	}
+
+	return a == b
+}
@@ -0,0 +1,69 @@
+// Package eagercache provides interfaces for threadsafe, mildly-generic, in-memory caches
+// These caches are intended to maximize performance as much as possible by helping to
+// minimize expensive operations such as locking OS calls (File Reads, Network calls, et cetera)
+// License: MIT
+package eagercache
+
+import (
+	"context"
+	"time"
+)
+
+// CreateCacheChain is CreateCache for values which can come from any of several sources, eg: a
+// fast cache tier, then a database, then a remote API. Misses and refreshes call the updaters in
+// order, stopping at the first one to return a non-nil value. When every updater returns nil, nil is
+// cached, as it would be for CreateCache.
+//
+// At least one updater is required, and all of them are expected to be threadsafe. opts apply as they
+// do for CreateCache.
+func CreateCacheChain(expireRate time.Duration, updaters []EntryUpdater, opts ...Option) *Cache {
+	if len(updaters) == 0 {
+		panic("the updater chain must hold at least one EntryUpdater")
+	}
+
+	for _, updater := range updaters {
+		if updater == nil {
+			panic("the updater-func must be a non-nil reference to an EntryUpdater")
+		}
+	}
+
+	return newCache(expireRate, func(_ context.Context, key string) (interface{}, time.Duration, error) {
+		for _, updater := range updaters {
+			if v := updater(key); v != nil {
+				return v, 0, nil
+			}
+		}
+
+		return nil, 0, nil
+	}, opts)
+}
+
+// CreateCacheChainE is CreateCacheChain for updaters which can fail, stopping at the first one to
+// return no error instead. An updater which fails is skipped, rather than failing the lookup, so
+// it's only when every updater fails that nothing is cached, exactly as when the one updater of a
+// CreateCacheE cache fails: a miss returns the last updater's error, and a refresh keeps the
+// previous value.
+//
+// At least one updater is required, and all of them are expected to be threadsafe. opts apply as they
+// do for CreateCache.
+func CreateCacheChainE(expireRate time.Duration, updaters []EntryUpdaterE, opts ...Option) *Cache {
+	if len(updaters) == 0 {
+		panic("the updater chain must hold at least one EntryUpdaterE")
+	}
+
+	for _, updater := range updaters {
+		if updater == nil {
+			panic("the updater-func must be a non-nil reference to an EntryUpdaterE")
+		}
+	}
+
+	return newCache(expireRate, func(_ context.Context, key string) (v interface{}, _ time.Duration, err error) {
+		for _, updater := range updaters {
+			if v, err = updater(key); err == nil {
+				return v, 0, nil
+			}
+		}
+
+		return nil, 0, err
+	}, opts)
+}
@@ -0,0 +1,34 @@
+package eagercache
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCreateCacheChain(t *testing.T) {
+	c := CreateCacheChain(time.Minute, []EntryUpdater{
+		func(key string) interface{} { return nil },
+		func(key string) interface{} { return key + "!" },
+	}, WithoutPool(), WithName("chain"))
+
+	if got := c.Retrieve("k"); got != "k!" {
+		t.Errorf("Retrieve(k) = %v, want the second updater's k!", got)
+	}
+
+	if name := c.Name(); name != "chain" {
+		t.Errorf("Name() = %q, the opts weren't applied", name)
+	}
+}
+
+func TestCreateCacheChainE(t *testing.T) {
+	errFirst := errors.New("first")
+	c := CreateCacheChainE(time.Minute, []EntryUpdaterE{
+		func(key string) (interface{}, error) { return nil, errFirst },
+		func(key string) (interface{}, error) { return key + "!", nil },
+	}, WithoutPool())
+
+	if got, err := c.RetrieveOrError("k"); err != nil || got != "k!" {
+		t.Errorf("RetrieveOrError(k) = %v, %v, want the second updater's k!", got, err)
+	}
+}
@@ -0,0 +1,106 @@
+// Package eagercache provides interfaces for threadsafe, mildly-generic, in-memory caches
+// These caches are intended to maximize performance as much as possible by helping to
+// minimize expensive operations such as locking OS calls (File Reads, Network calls, et cetera)
+// License: MIT
+package eagercache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+type (
+	// circuitBreaker counts the updater's failures, and opens once there are too many of them, see
+	// WithCircuitBreaker. It has its own lock, as the updater is never called under the cache's.
+	circuitBreaker struct {
+		mu        sync.Mutex
+		threshold int
+		window    time.Duration
+		cooldown  time.Duration
+
+		// failures holds when each failure since the last success happened, oldest first, trimmed to
+		// those within the window. openUntil is when an open circuit closes.
+		failures  []time.Time
+		openUntil time.Time
+	}
+
+	// refreshContextKey marks the contexts of background refreshes, whose deadline is the cache's
+	// own WithRefreshTimeout rather than a caller's
+	refreshContextKey struct{}
+)
+
+// ErrCircuitOpen is the error of the updater calls which WithCircuitBreaker's open circuit skipped
+var ErrCircuitOpen = errors.New("eagercache: the circuit breaker is open, the updater wasn't called")
+
+// WithCircuitBreaker protects a failing backend from the cache. Once the updater has failed
+// failureThreshold times within any window, with no success in between, the circuit opens, and for the following cooldown the
+// updater isn't called at all: misses fail fast with ErrCircuitOpen, which is returned by the
+// error-returning methods like RetrieveCtx, and the cleaner's refreshes fail likewise, so accessed
+// entries keep serving their last value, as for any failed refresh. After the cooldown the circuit
+// closes, and the count of failures starts over.
+//
+// Only the error-returning updaters, eg: from CreateCacheE, and panics, can fail. Calls which end
+// once the caller's own context is cancelled or past its deadline, eg: of RetrieveCtx, don't count
+// either way, but background refreshes which run out of WithRefreshTimeout count as failures.
+func WithCircuitBreaker(failureThreshold int, window, cooldown time.Duration) Option {
+	if failureThreshold <= 0 || window <= 0 || cooldown <= 0 {
+		panic("the circuit breaker's threshold, window and cooldown must all be positive")
+	}
+
+	return func(c *Cache) {
+		c.breaker = &circuitBreaker{threshold: failureThreshold, window: window, cooldown: cooldown}
+	}
+}
+
+// allow reports whether the updater may be called at n. A nil breaker always allows it.
+func (b *circuitBreaker) allow(n time.Time) bool {
+	if b == nil {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return !n.Before(b.openUntil)
+}
+
+// record counts the outcome of an updater call which ended at n: a success forgets the failures
+// before it, and a failure opens the circuit if it's the one which reached the threshold
+func (b *circuitBreaker) record(n time.Time, err error) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil {
+		b.failures = b.failures[:0]
+		return
+	}
+
+	// drop the failures which have left the window, in place, so the slice never outgrows threshold
+	expired := 0
+	for expired < len(b.failures) && n.Sub(b.failures[expired]) > b.window {
+		expired++
+	}
+	b.failures = append(b.failures[:copy(b.failures, b.failures[expired:])], n)
+
+	if len(b.failures) >= b.threshold {
+		b.openUntil = n.Add(b.cooldown)
+		b.failures = b.failures[:0]
+	}
+}
+
+// callerDone reports whether ctx, which an updater call was made under, was ended by the caller
+// rather than by WithRefreshTimeout, in which case the call's outcome says nothing of the backend
+func callerDone(ctx context.Context) bool {
+	return ctx.Err() != nil && ctx.Value(refreshContextKey{}) == nil
+}
+
+// open reports whether the circuit is open at n, for Stats
+func (b *circuitBreaker) open(n time.Time) bool {
+	return !b.allow(n)
+}
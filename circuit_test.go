@@ -0,0 +1,77 @@
+package eagercache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerResetsOnSuccess(t *testing.T) {
+	b := &circuitBreaker{threshold: 3, window: time.Minute, cooldown: time.Minute}
+	n := time.Now()
+	failed := errors.New("failed")
+
+	b.record(n, failed)
+	b.record(n, failed)
+	b.record(n, nil)
+	b.record(n, failed)
+	b.record(n, failed)
+	if b.open(n) {
+		t.Fatal("the circuit opened on 4 failures split by a success, want 2 in a row to be too few")
+	}
+
+	b.record(n, failed)
+	if !b.open(n) {
+		t.Fatal("the circuit didn't open on 3 failures in a row")
+	}
+}
+
+func TestCircuitBreakerRollingWindow(t *testing.T) {
+	b := &circuitBreaker{threshold: 3, window: time.Minute, cooldown: time.Minute}
+	n := time.Now()
+	failed := errors.New("failed")
+
+	// the first failure has left the window by the third
+	b.record(n, failed)
+	b.record(n.Add(40*time.Second), failed)
+	b.record(n.Add(70*time.Second), failed)
+	if b.open(n.Add(70 * time.Second)) {
+		t.Fatal("the circuit opened on 3 failures over more than a window")
+	}
+
+	// but the last 3 are within one, however the window aligns with when they started
+	b.record(n.Add(80*time.Second), failed)
+	if !b.open(n.Add(80 * time.Second)) {
+		t.Fatal("the circuit didn't open on 3 failures within a window")
+	}
+}
+
+func TestCircuitBreakerIgnoresCallerDeadline(t *testing.T) {
+	c := CreateCacheCtx(time.Minute, func(ctx context.Context, key string) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}, WithoutPool(), WithCircuitBreaker(1, time.Minute, time.Minute), WithRefreshTimeout(time.Millisecond))
+	defer c.Implode()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if _, err := c.RetrieveCtx(ctx, "k"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("RetrieveCtx past its deadline = %v, want context.DeadlineExceeded", err)
+	}
+
+	if c.Stats().CircuitOpen {
+		t.Fatal("the caller's own deadline opened the circuit")
+	}
+
+	// the deadline of WithRefreshTimeout is the backend's fault
+	refreshCtx, cancelRefresh := c.refreshContext()
+	defer cancelRefresh()
+	if _, _, err := c.loadWithin(refreshCtx, c.updater, "k"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("a timed out refresh = %v, want context.DeadlineExceeded", err)
+	}
+
+	if !c.Stats().CircuitOpen {
+		t.Fatal("a refresh which ran out of WithRefreshTimeout didn't open the circuit")
+	}
+}
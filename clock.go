@@ -0,0 +1,46 @@
+// Package eagercache provides interfaces for threadsafe, mildly-generic, in-memory caches
+// These caches are intended to maximize performance as much as possible by helping to
+// minimize expensive operations such as locking OS calls (File Reads, Network calls, et cetera)
+// License: MIT
+package eagercache
+
+import "time"
+
+type (
+	// Clock is what a Cache tells the time by, when filling, expiring and scrubbing entries. The
+	// default is the system clock; others are for tests which need to control expiry without sleeping.
+	Clock interface {
+		Now() time.Time
+	}
+
+	// scrubClock is the Clock the scrubber also sleeps by. NewTimer returns a channel which fires
+	// once d has passed, and a func which stops it early.
+	scrubClock interface {
+		Clock
+		NewTimer(d time.Duration) (fire <-chan time.Time, stop func() bool)
+	}
+
+	// realClock is the system clock
+	realClock struct{}
+)
+
+// WithClock has the cache tell the time by clk instead of the system clock, so tests can expire
+// entries deterministically. The cleaner still decides when to scrub the cache by the system clock.
+func WithClock(clk Clock) Option {
+	if clk == nil {
+		panic("the clock must be non-nil")
+	}
+
+	return func(c *Cache) {
+		c.clock = clk
+	}
+}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) (<-chan time.Time, func() bool) {
+	t := time.NewTimer(d)
+	return t.C, t.Stop
+}
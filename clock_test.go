@@ -0,0 +1,31 @@
+package eagercache
+
+import (
+	"sync"
+	"time"
+)
+
+// fakeClock is a Clock which only moves when advanced, for expiring entries without sleeping
+type fakeClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{t: time.Unix(1700000000, 0)}
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.t
+}
+
+// advance moves the clock d forward
+func (f *fakeClock) advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.t = f.t.Add(d)
+}
@@ -0,0 +1,115 @@
+// Package eagercache provides interfaces for threadsafe, mildly-generic, in-memory caches
+// These caches are intended to maximize performance as much as possible by helping to
+// minimize expensive operations such as locking OS calls (File Reads, Network calls, et cetera)
+// License: MIT
+package eagercache
+
+import (
+	"sort"
+	"time"
+)
+
+// debugEntries is how many of the soonest and latest expiring entries Debug lists
+const debugEntries = 10
+
+type (
+	// CacheDebug is everything about a cache an operator may want on a debug or status page, as
+	// returned by Cache.Debug. It's meant to be marshalled to JSON as-is, so durations are strings.
+	CacheDebug struct {
+		Name       string    `json:"name"`
+		Closed     bool      `json:"closed"`
+		Entries    int       `json:"entries"`
+		Hits       uint64    `json:"hits"`
+		Misses     uint64    `json:"misses"`
+		Evictions  uint64    `json:"evictions"`
+		Refreshes  uint64    `json:"refreshes"`
+		ExpireRate string    `json:"expire_rate"`
+		CleanRate  string    `json:"clean_rate"`
+		LastScrub  time.Time `json:"last_scrub"`
+		// Soonest and Latest list the entries which expire soonest and latest, up to 10 of each
+		Soonest []DebugEntry `json:"soonest_expiring"`
+		Latest  []DebugEntry `json:"latest_expiring"`
+	}
+
+	// DebugEntry describes a single entry listed by CacheDebug
+	DebugEntry struct {
+		Key       string    `json:"key"`
+		ExpiresAt time.Time `json:"expires_at"`
+		Accessed  bool      `json:"accessed"`
+		Pinned    bool      `json:"pinned"`
+	}
+)
+
+// Debug returns a snapshot of the cache for a debug endpoint: its counters, its settings, when the
+// cleaner last scrubbed it, and the entries which expire soonest and latest. The entries and
+// counters are read together under the cache's lock. It walks and sorts every entry, so it's meant
+// for operators, not for hot paths.
+func (c *Cache) Debug() CacheDebug {
+	// the pool's lock is taken first and on its own, as Implode takes the cache's lock before it
+	p.mu.RLock()
+	cleanRate := p.rateOf(c.cacheState)
+	var lastScrub time.Time
+	if s, ok := p.pool[c.cacheState]; ok {
+		lastScrub = s.last
+	}
+	p.mu.RUnlock()
+
+	c.mu.RLock()
+	d := CacheDebug{
+		Name:       c.name,
+		Closed:     c.closed,
+		Entries:    len(c.data),
+		Hits:       c.stats.hits.Load(),
+		Misses:     c.stats.misses.Load(),
+		Evictions:  c.stats.evictions.Load(),
+		Refreshes:  c.stats.refreshes.Load(),
+		ExpireRate: c.expireRate.String(),
+		CleanRate:  cleanRate.String(),
+		LastScrub:  lastScrub,
+	}
+
+	type listed struct {
+		key   string
+		entry expirable
+	}
+
+	entries := make([]listed, 0, len(c.data))
+	for key, entry := range c.data {
+		entries = append(entries, listed{key: key, entry: entry})
+	}
+	c.mu.RUnlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].entry.expiresAt.Before(entries[j].entry.expiresAt)
+	})
+
+	n := debugEntries
+	if len(entries) < n {
+		n = len(entries)
+	}
+
+	d.Soonest = make([]DebugEntry, n)
+	d.Latest = make([]DebugEntry, n)
+	for i := 0; i < n; i++ {
+		d.Soonest[i] = debugEntry(entries[i].key, entries[i].entry)
+		d.Latest[i] = debugEntry(entries[len(entries)-1-i].key, entries[len(entries)-1-i].entry)
+	}
+
+	return d
+}
+
+// debugEntry describes entry for CacheDebug. Pinned entries have a zero ExpiresAt, as their actual
+// expiresAt is too far off to marshal to JSON.
+func debugEntry(key string, entry expirable) DebugEntry {
+	d := DebugEntry{
+		Key:      key,
+		Accessed: entry.wasAccessedInInterval,
+		Pinned:   entry.pinned(),
+	}
+
+	if !d.Pinned {
+		d.ExpiresAt = entry.expiresAt
+	}
+
+	return d
+}
@@ -0,0 +1,85 @@
+// Package eagercache provides interfaces for threadsafe, mildly-generic, in-memory caches
+// These caches are intended to maximize performance as much as possible by helping to
+// minimize expensive operations such as locking OS calls (File Reads, Network calls, et cetera)
+// License: MIT
+package eagercache
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+type (
+	// DumpError is returned by Dump when marshal failed for some of the entries, which were left out
+	// of the dump. Failed maps each of their keys to marshal's error.
+	DumpError struct {
+		Failed map[string]error
+	}
+
+	// dumpLine is a line of Dump's output, a DebugEntry with the entry's marshalled value
+	dumpLine struct {
+		DebugEntry
+		Value json.RawMessage `json:"value"`
+	}
+)
+
+func (e *DumpError) Error() string {
+	keys := make([]string, 0, len(e.Failed))
+	for key := range e.Failed {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return fmt.Sprintf("eagercache: dump failed to marshal %d value(s), first %q: %v", len(keys), keys[0], e.Failed[keys[0]])
+}
+
+// Dump writes every entry of the cache to w, for inspecting a cache offline, eg: while debugging a
+// production issue. Each entry is written as a line of JSON, holding the fields of its DebugEntry
+// and its value as marshalled by marshal. Values which marshal to valid JSON, eg: by json.Marshal,
+// are embedded as-is, anything else as a string. The entries are streamed to w straight from the
+// map, so unlike Export, Dump doesn't copy the cache, but it holds the read lock throughout, and
+// writers wait on w; keep w to a buffered file or similar.
+//
+// Entries whose value marshal fails on are skipped, and reported, once the rest are written, by a
+// *DumpError. An error from w ends the dump, and is returned as-is.
+func (c *Cache) Dump(w io.Writer, marshal func(interface{}) ([]byte, error)) error {
+	enc := json.NewEncoder(w)
+
+	var failed map[string]error
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for key, entry := range c.data {
+		if isAbsent(entry.value) {
+			continue
+		}
+
+		b, err := marshal(entry.value)
+		if err != nil {
+			if failed == nil {
+				failed = map[string]error{}
+			}
+			failed[key] = err
+			continue
+		}
+
+		if !json.Valid(b) {
+			if b, err = json.Marshal(string(b)); err != nil {
+				return err
+			}
+		}
+
+		if err := enc.Encode(dumpLine{DebugEntry: debugEntry(key, entry), Value: b}); err != nil {
+			return err
+		}
+	}
+
+	if failed != nil {
+		return &DumpError{Failed: failed}
+	}
+
+	return nil
+}
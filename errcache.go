@@ -0,0 +1,56 @@
+// Package eagercache provides interfaces for threadsafe, mildly-generic, in-memory caches
+// These caches are intended to maximize performance as much as possible by helping to
+// minimize expensive operations such as locking OS calls (File Reads, Network calls, et cetera)
+// License: MIT
+package eagercache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+type (
+	// cachedError is what a WithErrorCaching cache stores for a key whose fill failed. It's handed
+	// out as nil, and as its err by the error-returning methods.
+	cachedError struct {
+		err error
+	}
+)
+
+// WithErrorCaching caches the errors of the updater, as well as its values, for ttl, so a key whose
+// backend keeps failing, eg: during an outage, returns its error straight from the cache, instead of
+// calling the updater on every Retrieve. RetrieveOrError and RetrieveCtx return the cached error, like
+// the one they got from the updater, while Retrieve returns nil. Errors from the caller's context and
+// from WithCircuitBreaker's open circuit aren't cached, nor are failed refreshes, which keep serving
+// the last good value as usual.
+//
+// A cached error is never refreshed or served stale: it's evicted at expiry, and the next Retrieve
+// calls the updater again. So for ttl, a key is reported as failing even once its backend recovers;
+// keep ttl short, eg: a few seconds, to absorb error storms without masking recoveries.
+func WithErrorCaching(ttl time.Duration) Option {
+	if ttl <= 0 {
+		panic("the error ttl must be positive")
+	}
+
+	return func(c *Cache) {
+		c.errorTTL = ttl
+		c.tracksFound = true
+	}
+}
+
+// cachesError reports whether err, returned when filling an entry, is to be stored as a cachedError
+func (c *Cache) cachesError(err error) bool {
+	if c.errorTTL <= 0 {
+		return false
+	}
+
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) &&
+		!errors.Is(err, ErrCircuitOpen) && !errors.Is(err, ErrCacheClosed)
+}
+
+// isCachedError reports whether v is a cachedError
+func isCachedError(v interface{}) bool {
+	_, failed := v.(cachedError)
+	return failed
+}
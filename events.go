@@ -0,0 +1,126 @@
+// Package eagercache provides interfaces for threadsafe, mildly-generic, in-memory caches
+// These caches are intended to maximize performance as much as possible by helping to
+// minimize expensive operations such as locking OS calls (File Reads, Network calls, et cetera)
+// License: MIT
+package eagercache
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// eventBuffer is how many events a subscriber's channel holds before further events are dropped
+const eventBuffer = 64
+
+type (
+	// EventKind is what happened to the entry an Event is about
+	EventKind int
+
+	// Event is something which happened to one of a cache's entries, as received from Subscribe
+	Event struct {
+		Kind EventKind
+		Key  string
+	}
+
+	// eventHub fans a cache's events out to its subscribers. It has its own lock, as events are
+	// published with the cache's lock held, and subscribers is checked first so that publishing is
+	// a single atomic load while nobody is subscribed.
+	eventHub struct {
+		mu          sync.RWMutex
+		subs        map[chan Event]struct{}
+		subscribers atomic.Int32
+		dropped     atomic.Uint64
+
+		// closed is set by closeAll, under mu, so that a Subscribe racing Implode can't add a
+		// channel after every channel has been closed
+		closed bool
+	}
+)
+
+const (
+	// EventMiss is published for every Retrieve which had to call the updater
+	EventMiss EventKind = iota
+	// EventEvict is published for every entry the cache evicts by itself
+	EventEvict
+	// EventRefresh is published for every entry the cleaner, or RefreshMatching, refreshed
+	EventRefresh
+)
+
+// Subscribe returns a channel of the cache's misses, evictions and refreshes, eg: to mirror
+// evictions to a distributed cache, along with a func which unsubscribes, and closes the channel.
+// Events are never waited on: once the channel holds 64 unreceived events, further ones are dropped,
+// and counted as DroppedEvents in Stats, so a slow subscriber can't stall the cache. Imploding the
+// cache closes every subscriber's channel, and subscribing to an imploded cache returns a closed one.
+func (c *Cache) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, eventBuffer)
+
+	h := &c.events
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		close(ch)
+		return ch, func() {}
+	}
+
+	if h.subs == nil {
+		h.subs = map[chan Event]struct{}{}
+	}
+	h.subs[ch] = struct{}{}
+	h.subscribers.Add(1)
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		if _, ok := h.subs[ch]; ok {
+			delete(h.subs, ch)
+			h.subscribers.Add(-1)
+			close(ch)
+		}
+	}
+}
+
+// publish sends e to every subscriber with room for it, without blocking
+func (h *eventHub) publish(kind EventKind, key string) {
+	if h.subscribers.Load() == 0 {
+		return
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for ch := range h.subs {
+		select {
+		case ch <- Event{Kind: kind, Key: key}:
+		default:
+			h.dropped.Add(1)
+		}
+	}
+}
+
+// closeAll unsubscribes every subscriber, closing their channels
+func (h *eventHub) closeAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subs {
+		close(ch)
+	}
+	h.subs = nil
+	h.subscribers.Store(0)
+	h.closed = true
+}
+
+func (k EventKind) String() string {
+	switch k {
+	case EventMiss:
+		return "miss"
+	case EventEvict:
+		return "evict"
+	case EventRefresh:
+		return "refresh"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,27 @@
+package eagercache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSubscribeAfterEventsClosed(t *testing.T) {
+	c := CreateCache(time.Minute, func(key string) interface{} { return key }, WithoutPool())
+	defer c.Implode()
+
+	// as if Implode closed the subscribers between a Subscribe's check of the cache and its
+	// subscription
+	c.events.closeAll()
+
+	ch, unsubscribe := c.Subscribe()
+	defer unsubscribe()
+
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Fatal("received an event, want the channel closed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("subscribing once the subscribers were closed returned an open channel")
+	}
+}
@@ -0,0 +1,34 @@
+// Package eagercache provides interfaces for threadsafe, mildly-generic, in-memory caches
+// These caches are intended to maximize performance as much as possible by helping to
+// minimize expensive operations such as locking OS calls (File Reads, Network calls, et cetera)
+// License: MIT
+package eagercache
+
+// WithFinalizer is a safety net for caches which may be dropped without Implode, eg: short-lived
+// caches created per request: once the *Cache is unreachable, a finalizer implodes it, removing it
+// from the pool instead of having the cleaner scrub it forever. Only the *Cache returned by the
+// constructor counts, the handles returned by AllCaches don't keep the cache alive, and see it as
+// imploded once it's finalized. The finalizer runs at some garbage collection after the cache
+// becomes unreachable, not promptly, and calls Implode rather than ImplodeWith, so Implode is still
+// the way to release a cache and its values deterministically.
+func WithFinalizer() Option {
+	return func(c *Cache) {
+		c.finalize = true
+	}
+}
+
+// finalizeCache implodes a cache created WithFinalizer which became unreachable. It only touches the
+// state, so c is unreachable again once it returns.
+func finalizeCache(c *Cache) {
+	c.Implode()
+}
+
+// cache returns the Cache the state belongs to, or, for caches created WithFinalizer, a new handle
+// on it, which doesn't keep the original from being finalized
+func (s *cacheState) cache() *Cache {
+	if s.handle != nil {
+		return s.handle
+	}
+
+	return &Cache{cacheState: s}
+}
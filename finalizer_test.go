@@ -0,0 +1,58 @@
+package eagercache
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+// pooledState reports whether s is registered with the pool
+func pooledState(s *cacheState) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	_, ok := p.pool[s]
+	return ok
+}
+
+func TestWithFinalizerRemovesDroppedCache(t *testing.T) {
+	c := CreateCache(time.Minute, func(key string) interface{} { return key }, WithFinalizer())
+	c.Retrieve("k")
+
+	// the state doesn't keep the handle reachable, so holding it is fine
+	s := c.cacheState
+	c = nil
+
+	deadline := time.Now().Add(5 * time.Second)
+	for pooledState(s) {
+		if time.Now().After(deadline) {
+			t.Fatal("the dropped cache is still pooled")
+		}
+
+		runtime.GC()
+		time.Sleep(time.Millisecond)
+	}
+
+	s.mu.RLock()
+	closed := s.closed
+	s.mu.RUnlock()
+
+	if !closed {
+		t.Error("the finalizer removed the cache from the pool without imploding it")
+	}
+}
+
+func TestWithoutFinalizerKeepsCachePooled(t *testing.T) {
+	c := CreateCache(time.Minute, func(key string) interface{} { return key })
+	s := c.cacheState
+	c = nil
+
+	runtime.GC()
+	runtime.GC()
+
+	if !pooledState(s) {
+		t.Fatal("a cache created without WithFinalizer left the pool")
+	}
+
+	s.cache().Implode()
+}
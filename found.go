@@ -0,0 +1,76 @@
+// Package eagercache provides interfaces for threadsafe, mildly-generic, in-memory caches
+// These caches are intended to maximize performance as much as possible by helping to
+// minimize expensive operations such as locking OS calls (File Reads, Network calls, et cetera)
+// License: MIT
+package eagercache
+
+import (
+	"context"
+	"time"
+)
+
+type (
+	// EntryUpdaterFound is the updater of CreateCacheFound, which reports whether key exists at all,
+	// rather than conflating an absent key with a nil value
+	EntryUpdaterFound func(key string) (value interface{}, found bool)
+
+	// absentValue is what a CreateCacheFound cache stores for a key its updater didn't find. It's
+	// handed out as nil.
+	absentValue struct{}
+)
+
+// CreateCacheFound is CreateCache for a read-through cache which tells apart three states for each
+// key: present, known to be absent, and unknown. Keys the updater reports as not found are cached as
+// such for negativeTTL, so repeated lookups of them don't hit the backend until then, while found
+// values are cached for expireRate as usual. Retrieve returns nil for known-absent keys, use
+// RetrieveFound to tell them apart from cached nils.
+//
+// The updater func is required and expected to be threadsafe.
+func CreateCacheFound(expireRate, negativeTTL time.Duration, updater EntryUpdaterFound, opts ...Option) *Cache {
+	if updater == nil {
+		panic("the updater-func must be a non-nil reference to an EntryUpdaterFound")
+	}
+
+	return newCache(expireRate, func(_ context.Context, key string) (interface{}, time.Duration, error) {
+		v, found := updater(key)
+		if !found {
+			return absentValue{}, 0, nil
+		}
+
+		return v, 0, nil
+	}, append(opts, WithNegativeTTL(negativeTTL, isAbsent), func(c *Cache) {
+		c.tracksFound = true
+	}))
+}
+
+// RetrieveFound is Retrieve, additionally reporting whether key was found, so a known-absent key can
+// be told apart from a cached nil value. Keys of caches not created by CreateCacheFound are always
+// found, unless the updater failed or the cache is imploded.
+func (c *Cache) RetrieveFound(key string) (interface{}, bool) {
+	cached, _, err := c.retrieve(context.Background(), key, nil)
+	if err != nil || isAbsent(cached.value) {
+		return nil, false
+	}
+
+	return c.copyOut(cached.value), true
+}
+
+// valueOf returns v as callbacks see it: nil for a placeholder, see isAbsent, and v otherwise
+func valueOf(v interface{}) interface{} {
+	if isAbsent(v) {
+		return nil
+	}
+
+	return v
+}
+
+// isAbsent reports whether v is a placeholder stored instead of a value, ie: an absentValue or a
+// cachedError
+func isAbsent(v interface{}) bool {
+	switch v.(type) {
+	case absentValue, cachedError:
+		return true
+	default:
+		return false
+	}
+}
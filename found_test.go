@@ -0,0 +1,31 @@
+package eagercache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCreateCacheFoundPlaceholdersStayInternal(t *testing.T) {
+	var evicted []interface{}
+	c := CreateCacheFound(time.Minute, time.Minute, func(key string) (interface{}, bool) {
+		return key, key != "missing"
+	}, WithoutPool(), WithOnEvict(func(key string, value interface{}) {
+		evicted = append(evicted, value)
+	}))
+
+	if v, ok := c.RetrieveFound("missing"); ok || v != nil {
+		t.Fatalf("RetrieveFound(missing) = %v, %v, want nil, false", v, ok)
+	}
+	c.Retrieve("present")
+
+	if exported := c.Export(); len(exported) != 1 || exported["present"] != "present" {
+		t.Errorf("Export() = %v, want only present", exported)
+	}
+
+	c.Delete("missing")
+	c.Flush()
+
+	if len(evicted) != 1 || evicted[0] != "present" {
+		t.Errorf("OnEvict saw %v, want only present's value", evicted)
+	}
+}
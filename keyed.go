@@ -0,0 +1,43 @@
+// Package eagercache provides interfaces for threadsafe, mildly-generic, in-memory caches
+// These caches are intended to maximize performance as much as possible by helping to
+// minimize expensive operations such as locking OS calls (File Reads, Network calls, et cetera)
+// License: MIT
+package eagercache
+
+import "time"
+
+type (
+	// KeyedCache is a Cache whose keys are built from several parts, eg: a tenant and a resource ID,
+	// by a single keyFunc, rather than concatenated by hand at every call site, where a careless
+	// separator lets "a:bc" and "ab:c" collide. The embedded Cache's methods take the canonical key,
+	// as returned by Key. For keys which are comparable structs, see CreateTypedCache instead.
+	KeyedCache struct {
+		*Cache
+		keyFunc func(args ...interface{}) string
+	}
+)
+
+// CreateCacheKeyed allocates a KeyedCache, which behaves exactly like a Cache from CreateCache. The
+// updater is called with the canonical key returned by keyFunc, which must be threadsafe, and must
+// never return the same key for different args.
+func CreateCacheKeyed(expireRate time.Duration, keyFunc func(args ...interface{}) string, updater EntryUpdater, opts ...Option) *KeyedCache {
+	if keyFunc == nil {
+		panic("the keyFunc must be non-nil")
+	}
+
+	return &KeyedCache{
+		Cache:   CreateCache(expireRate, updater, opts...),
+		keyFunc: keyFunc,
+	}
+}
+
+// Key returns the canonical key of args, for use with the methods of the embedded Cache
+func (kc *KeyedCache) Key(args ...interface{}) string {
+	return kc.keyFunc(args...)
+}
+
+// Retrieve a value from the cache by the canonical key of args. On a cache miss, calls the updater
+// func with the canonical key.
+func (kc *KeyedCache) Retrieve(args ...interface{}) interface{} {
+	return kc.Cache.Retrieve(kc.keyFunc(args...))
+}
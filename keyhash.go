@@ -0,0 +1,37 @@
+// Package eagercache provides interfaces for threadsafe, mildly-generic, in-memory caches
+// These caches are intended to maximize performance as much as possible by helping to
+// minimize expensive operations such as locking OS calls (File Reads, Network calls, et cetera)
+// License: MIT
+package eagercache
+
+// WithKeyHasher makes the cache store its entries under hash(key) rather than key itself, while the
+// updater is still called with the key passed to Retrieve. For caches of millions of entries with
+// long keys, eg: serialized request blobs, storing a short digest of each key instead saves most of
+// the memory the keys would take.
+//
+// Two keys with the same hash share one entry, and one of them is served the other's value, so hash
+// has to be collision resistant for the keyspace, eg: a hex-encoded SHA-256, not a 32-bit checksum.
+//
+// The original key isn't kept past the call which filled the entry, so there's nothing to refresh
+// an expired entry with. Entries of a hashed cache expire and are re-filled by the next Retrieve,
+// instead of being refreshed eagerly, and RefreshMatching is a no-op. Everything which reports keys
+// back, eg: Keys, Range, DeletePrefix, OnEvict and Subscribe, reports the hashed ones. Export panics,
+// as hashed keys can't be Imported back: Import, like Set, hashes the keys it's given.
+func WithKeyHasher(hash func(key string) string) Option {
+	if hash == nil {
+		panic("the key hasher must be a non-nil func(string) string")
+	}
+
+	return func(c *Cache) {
+		c.keyHasher = hash
+	}
+}
+
+// storedKey returns the key the entry for key is stored under
+func (c *Cache) storedKey(key string) string {
+	if c.keyHasher == nil {
+		return key
+	}
+
+	return c.keyHasher(key)
+}
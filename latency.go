@@ -0,0 +1,125 @@
+// Package eagercache provides interfaces for threadsafe, mildly-generic, in-memory caches
+// These caches are intended to maximize performance as much as possible by helping to
+// minimize expensive operations such as locking OS calls (File Reads, Network calls, et cetera)
+// License: MIT
+package eagercache
+
+import (
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+// latencySubBits is how many bits of each duration, below its leading one, pick its bucket. Each
+// power of two is split into 1<<latencySubBits buckets, so percentiles are within 25% of the truth.
+const latencySubBits = 2
+
+type (
+	// latencyHistogram accumulates updater call durations, in nanoseconds, HDR-style: in buckets
+	// which grow exponentially, so that recording is a few atomic adds and the memory is fixed.
+	latencyHistogram struct {
+		count   atomic.Uint64
+		sum     atomic.Uint64
+		min     atomic.Uint64
+		max     atomic.Uint64
+		buckets [64 << latencySubBits]atomic.Uint64
+	}
+
+	// latencySummary is what a latencyHistogram reports into Stats
+	latencySummary struct {
+		count               uint64
+		min, max, mean, p99 time.Duration
+	}
+)
+
+// record adds a call which took d
+func (h *latencyHistogram) record(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+
+	ns := uint64(d)
+	h.buckets[latencyBucket(ns)].Add(1)
+	h.sum.Add(ns)
+
+	// min is stored off by one, so that its zero value means there's been no call yet
+	for cur := h.min.Load(); cur == 0 || ns+1 < cur; cur = h.min.Load() {
+		if h.min.CompareAndSwap(cur, ns+1) {
+			break
+		}
+	}
+
+	for cur := h.max.Load(); ns > cur; cur = h.max.Load() {
+		if h.max.CompareAndSwap(cur, ns) {
+			break
+		}
+	}
+
+	h.count.Add(1)
+}
+
+// summary reads the histogram. Like Stats, it's only consistent when no call is being recorded.
+func (h *latencyHistogram) summary() latencySummary {
+	s := latencySummary{count: h.count.Load()}
+	if s.count == 0 {
+		return s
+	}
+
+	if m := h.min.Load(); m > 0 {
+		s.min = time.Duration(m - 1)
+	}
+	s.max = time.Duration(h.max.Load())
+	s.mean = time.Duration(h.sum.Load() / s.count)
+
+	rank := (s.count*99 + 99) / 100
+	var seen uint64
+	for i := range h.buckets {
+		if seen += h.buckets[i].Load(); seen >= rank {
+			s.p99 = time.Duration(latencyBucketMax(i))
+			break
+		}
+	}
+
+	if s.p99 > s.max {
+		s.p99 = s.max
+	}
+
+	return s
+}
+
+// reset zeroes the histogram
+func (h *latencyHistogram) reset() {
+	h.count.Store(0)
+	h.sum.Store(0)
+	h.min.Store(0)
+	h.max.Store(0)
+	for i := range h.buckets {
+		h.buckets[i].Store(0)
+	}
+}
+
+// latencyBucket returns the index of the bucket holding ns. Durations under 1<<latencySubBits get a
+// bucket each, the rest are bucketed by their leading one and the latencySubBits bits below it.
+func latencyBucket(ns uint64) int {
+	if ns < 1<<latencySubBits {
+		return int(ns)
+	}
+
+	exp := bits.Len64(ns) - 1
+	mantissa := (ns >> (exp - latencySubBits)) & (1<<latencySubBits - 1)
+
+	return (exp-latencySubBits+1)<<latencySubBits | int(mantissa)
+}
+
+// latencyBucketMax returns the largest duration, in nanoseconds, which falls into bucket i
+func latencyBucketMax(i int) uint64 {
+	if i < 1<<latencySubBits {
+		return uint64(i)
+	}
+
+	exp := i>>latencySubBits + latencySubBits - 1
+	mantissa := uint64(i & (1<<latencySubBits - 1))
+	width := uint64(1) << (exp - latencySubBits)
+
+	return (1<<latencySubBits|mantissa)*width + width - 1
+}
@@ -0,0 +1,49 @@
+// Package eagercache provides interfaces for threadsafe, mildly-generic, in-memory caches
+// These caches are intended to maximize performance as much as possible by helping to
+// minimize expensive operations such as locking OS calls (File Reads, Network calls, et cetera)
+// License: MIT
+package eagercache
+
+type (
+	// LogLevel is how much attention a message passed to a Logger deserves
+	LogLevel int
+
+	// Logger receives a cache's decisions as they happen, for diagnosing eg: stale data, without this
+	// package depending on any logging library. keyvals alternate between string keys and their
+	// values, slog-style, and always start with the cache's WithName name. Only misses and background
+	// events are logged, never hits. Log is called synchronously, sometimes with the cache's lock
+	// held, so it must be fast and must not call back into the cache.
+	Logger interface {
+		Log(level LogLevel, msg string, keyvals ...interface{})
+	}
+)
+
+const (
+	// LogDebug is for the cache's routine decisions: misses, evictions, refreshes and scrubs
+	LogDebug LogLevel = iota
+	// LogError is for failures, ie: updater calls which returned an error or panicked
+	LogError
+)
+
+// WithLogger has the cache log its misses, evictions, refreshes, failed updater calls and cleaning
+// passes to l. Without it nothing is logged, and nothing is spent building the messages.
+func WithLogger(l Logger) Option {
+	if l == nil {
+		panic("the logger must be non-nil")
+	}
+
+	return func(c *Cache) {
+		c.logger = l
+	}
+}
+
+func (l LogLevel) String() string {
+	switch l {
+	case LogDebug:
+		return "debug"
+	case LogError:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
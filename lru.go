@@ -0,0 +1,83 @@
+// Package eagercache provides interfaces for threadsafe, mildly-generic, in-memory caches
+// These caches are intended to maximize performance as much as possible by helping to
+// minimize expensive operations such as locking OS calls (File Reads, Network calls, et cetera)
+// License: MIT
+package eagercache
+
+import "container/list"
+
+// touchLocked marks entry as the most recently used and, if that grew the cache past maxEntries or
+// maxBytes, evicts from the least recently used end, returning what it evicted for notifyEvicted.
+// The caller must hold c.mu for writing and store entry back into c.data afterwards.
+func (c *Cache) touchLocked(key string, entry *expirable) (evicted []removal) {
+	if c.lru == nil {
+		return nil
+	}
+
+	c.resizeLocked(entry)
+
+	if entry.elem != nil {
+		c.lru.MoveToFront(entry.elem)
+		return c.trimLocked(entry.elem, 0, nil)
+	}
+
+	entry.elem = c.lru.PushFront(key)
+
+	// key isn't in c.data yet when it's new, hence the pending entry
+	return c.trimLocked(entry.elem, 1, nil)
+}
+
+// trimLocked evicts from the least recently used end until the cache is back within its limits,
+// counting pending entries which are about to be stored into c.data. keep, the entry being stored,
+// is never evicted, so a single value larger than maxBytes still gets cached, alone.
+// The caller must hold c.mu for writing.
+func (c *Cache) trimLocked(keep *list.Element, pending int, evicted []removal) []removal {
+	if c.lru == nil {
+		return evicted
+	}
+
+	for (c.maxEntries > 0 && len(c.data)+pending > c.maxEntries) || (c.maxBytes > 0 && c.usedBytes.Load() > c.maxBytes) {
+		oldest := c.lru.Back()
+		if oldest == nil || oldest == keep {
+			break
+		}
+
+		k := oldest.Value.(string)
+		evicted = c.evictLocked(k, c.data[k], evicted)
+	}
+
+	return evicted
+}
+
+// removeLocked deletes key from the cache, along with its place in the LRU order.
+// The caller must hold c.mu for writing.
+func (c *Cache) removeLocked(key string, entry expirable) {
+	delete(c.data, key)
+	c.usedBytes.Add(-entry.size)
+
+	if entry.elem != nil {
+		c.lru.Remove(entry.elem)
+	}
+}
+
+// resizeLocked re-estimates the size of entry's value, keeping usedBytes in step. Placeholders, eg:
+// cached errors, take no space, and are never handed to sizeOf. The caller must hold c.mu for
+// writing and store entry back into c.data afterwards.
+func (c *Cache) resizeLocked(entry *expirable) {
+	if c.sizeOf == nil {
+		return
+	}
+
+	var size int64
+	if !isAbsent(entry.value) {
+		size = c.sizeOf(entry.value)
+	}
+	c.usedBytes.Add(size - entry.size)
+	entry.size = size
+}
+
+// Bytes reports the sum of the sizes of the cached values, as estimated by the sizeOf func given to
+// CreateCacheWithByteLimit. It's always 0 for other caches.
+func (c *Cache) Bytes() int64 {
+	return c.usedBytes.Load()
+}
@@ -0,0 +1,46 @@
+package eagercache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCreateCacheWithLimit(t *testing.T) {
+	const n, k = 10, 5
+	c := CreateCacheWithLimit(time.Minute, n, func(key string) interface{} { return key }, WithoutPool())
+
+	for i := 0; i < n+k; i++ {
+		c.Retrieve(strconv.Itoa(i))
+	}
+
+	if got := c.Len(); got != n {
+		t.Fatalf("Len() = %d after %d misses, want the cap of %d", got, n+k, n)
+	}
+
+	// the first k keys were the least recently used
+	for i := 0; i < n+k; i++ {
+		_, ok := c.Peek(strconv.Itoa(i))
+		if want := i >= k; ok != want {
+			t.Errorf("Peek(%d) present = %v, want %v", i, ok, want)
+		}
+	}
+}
+
+func TestCreateCacheWithLimitKeepsRecentlyUsed(t *testing.T) {
+	c := CreateCacheWithLimit(time.Minute, 2, func(key string) interface{} { return key }, WithoutPool())
+
+	// Set entries start out unaccessed, so a's first Retrieve of the interval makes it the most recent
+	c.Set("a", "a")
+	c.Set("b", "b")
+	c.Retrieve("a")
+	c.Retrieve("c")
+
+	if _, ok := c.Peek("a"); !ok {
+		t.Error("a was evicted, though it was used more recently than b")
+	}
+
+	if _, ok := c.Peek("b"); ok {
+		t.Error("b is still cached, though it was the least recently used")
+	}
+}
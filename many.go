@@ -0,0 +1,203 @@
+// Package eagercache provides interfaces for threadsafe, mildly-generic, in-memory caches
+// These caches are intended to maximize performance as much as possible by helping to
+// minimize expensive operations such as locking OS calls (File Reads, Network calls, et cetera)
+// License: MIT
+package eagercache
+
+import (
+	"context"
+	"time"
+)
+
+type (
+	// loaded is a value fetched for one of the misses of a RetrieveMany
+	loaded struct {
+		value interface{}
+		ttl   time.Duration
+		ok    bool
+		// shared is set for values which were already stored by the fill which loaded them
+		shared bool
+	}
+)
+
+// RetrieveMany returns the values of all of keys, keyed by key. The hits are collected under a
+// single read lock, the misses are then filled through the updater, concurrently under the limit of
+// WithRefreshConcurrency, and stored under a single write lock. Keys whose updater call failed are
+// left out of the result.
+//
+// Unlike Retrieve, RetrieveMany doesn't coalesce its updater calls with concurrent misses elsewhere.
+func (c *Cache) RetrieveMany(keys []string) map[string]interface{} {
+	return c.retrieveMany(keys, func(misses []string) []loaded {
+		c.mu.RLock()
+		updater := c.updater
+		c.mu.RUnlock()
+
+		fills := make([]loaded, len(misses))
+		boundedRun(c.refreshLimit(), len(misses), func(i int) {
+			v, ttl, err := c.safeLoad(context.Background(), updater, misses[i])
+			fills[i] = loaded{value: v, ttl: ttl, ok: err == nil}
+		})
+
+		return fills
+	})
+}
+
+// RetrieveBatch is RetrieveMany, returning the values in the order of keys, eg: for GraphQL-style
+// resolvers. Like RetrieveMany, duplicate keys only cost one updater call. Unlike it, each miss is
+// filled and stored the way Retrieve fills it, coalesced with concurrent fills of the same key, whether
+// from Retrieve or another RetrieveBatch, so overlapping batches share their updater calls, and the
+// callers which join a fill see the entry exactly as it was stored. Keys whose updater call failed get
+// a nil value.
+func (c *Cache) RetrieveBatch(keys []string) []interface{} {
+	values := c.retrieveMany(keys, func(misses []string) []loaded {
+		fills := make([]loaded, len(misses))
+		boundedRun(c.refreshLimit(), len(misses), func(i int) {
+			ctx := context.Background()
+			entry, err := c.flights.do(ctx, misses[i], func() (expirable, error) {
+				return c.fillEntry(ctx, misses[i], nil, true)
+			})
+			fills[i] = loaded{value: entry.value, ok: err == nil, shared: true}
+		})
+
+		return fills
+	})
+
+	ordered := make([]interface{}, len(keys))
+	for i, key := range keys {
+		ordered[i] = values[key]
+	}
+
+	return ordered
+}
+
+// RetrieveManyBatch is RetrieveMany, except that all of the misses are filled by a single call to
+// batch, so N misses cost one backend round-trip instead of N. Keys missing from batch's result are
+// left out of RetrieveManyBatch's result too, and aren't cached.
+func (c *Cache) RetrieveManyBatch(keys []string, batch func([]string) map[string]interface{}) map[string]interface{} {
+	return c.retrieveMany(keys, func(misses []string) []loaded {
+		values := batch(misses)
+
+		fills := make([]loaded, len(misses))
+		for i, key := range misses {
+			fills[i].value, fills[i].ok = values[key]
+		}
+
+		return fills
+	})
+}
+
+// retrieveMany implements RetrieveMany and RetrieveManyBatch, fill returns a loaded for each miss
+func (c *Cache) retrieveMany(keys []string, fill func(misses []string) []loaded) map[string]interface{} {
+	values := make(map[string]interface{}, len(keys))
+
+	// marks are present entries which have to be marked as accessed, misses need filling
+	var marks, misses []string
+
+	c.mu.RLock()
+	if c.closed {
+		c.mu.RUnlock()
+		return values
+	}
+
+	n := c.clock.Now()
+	for _, key := range keys {
+		if _, dup := values[key]; dup {
+			continue
+		}
+
+		entry, ok := c.data[c.storedKey(key)]
+		switch {
+		case ok && c.readHit(entry):
+			values[key] = entry.value
+		case ok && c.servable(entry, n):
+			values[key] = entry.value
+			marks = append(marks, key)
+		default:
+			values[key] = nil
+			misses = append(misses, key)
+		}
+	}
+	c.mu.RUnlock()
+
+	c.countHits(len(values) - len(misses))
+	c.countMisses(len(misses))
+	for _, key := range misses {
+		if c.logger != nil {
+			c.logger.Log(LogDebug, "cache miss", "cache", c.name, "key", key)
+		}
+		c.events.publish(EventMiss, key)
+	}
+
+	if len(marks) == 0 && len(misses) == 0 && c.copyOnRetrieve == nil && !c.tracksFound {
+		return values
+	}
+
+	var fills []loaded
+	if len(misses) > 0 {
+		fills = fill(misses)
+	}
+
+	for i, key := range misses {
+		if fills[i].ok && !fills[i].shared {
+			c.validate(key, fills[i].value)
+		}
+	}
+
+	var evicted []removal
+
+	c.mu.Lock()
+	n = c.clock.Now()
+	for _, key := range marks {
+		key = c.storedKey(key)
+		entry, ok := c.data[key]
+		if !ok {
+			continue
+		}
+
+		entry.wasAccessedInInterval = true
+		if c.sliding && !entry.pinned() {
+			entry.expiresAt = c.expiresFrom(n, entry.value, 0)
+		}
+		evicted = append(evicted, c.touchLocked(key, &entry)...)
+		c.data[key] = entry
+	}
+
+	for i, key := range misses {
+		if !fills[i].ok {
+			delete(values, key)
+			continue
+		}
+
+		values[key] = fills[i].value
+		if c.closed || fills[i].shared {
+			continue
+		}
+
+		key = c.storedKey(key)
+		entry := c.data[key]
+		entry.value = fills[i].value
+		entry.expiresAt = c.expiresFrom(n, fills[i].value, fills[i].ttl)
+		entry.createdAt = n
+		entry.version = c.nextVersionLocked()
+		entry.wasAccessedInInterval = true
+		evicted = append(evicted, c.touchLocked(key, &entry)...)
+		c.data[key] = entry
+	}
+	c.mu.Unlock()
+
+	c.notifyEvicted(evicted)
+
+	if c.copyOnRetrieve != nil || c.tracksFound {
+		for key, v := range values {
+			// errors cached by WithErrorCaching are failed fills, which are left out
+			if isCachedError(v) {
+				delete(values, key)
+				continue
+			}
+
+			values[key] = c.copyOut(v)
+		}
+	}
+
+	return values
+}
@@ -0,0 +1,57 @@
+package eagercache
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetrieveBatchJoinersSeeStoredEntry(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	var calls atomic.Int32
+	c := CreateCache(time.Minute, func(key string) interface{} {
+		if calls.Add(1) == 1 {
+			close(entered)
+			<-release
+		}
+		return key
+	}, WithoutPool())
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.RetrieveBatch([]string{"k"})
+	}()
+	<-entered
+
+	type result struct {
+		ttl     time.Duration
+		version uint64
+	}
+	joined := make(chan result)
+	go func() {
+		_, version, _ := c.RetrieveVersioned("k")
+		_, ttl := c.RetrieveWithTTL("k")
+		joined <- result{ttl: ttl, version: version}
+	}()
+
+	// the batch's fill is only let through once the Retrieve has joined it
+	eventually(t, "the Retrieve never joined the batch's fill", func() bool {
+		return c.flights.joined("k") == 1
+	})
+	close(release)
+	<-done
+	got := <-joined
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("updater called %d times, want once", n)
+	}
+
+	if _, version, _ := c.RetrieveVersioned("k"); got.version == 0 || got.version != version {
+		t.Errorf("joiner got version %d, want the stored %d", got.version, version)
+	}
+
+	if got.ttl <= 0 {
+		t.Errorf("joiner got a TTL of %v, want about a minute", got.ttl)
+	}
+}
@@ -0,0 +1,59 @@
+// Package eagercache provides interfaces for threadsafe, mildly-generic, in-memory caches
+// These caches are intended to maximize performance as much as possible by helping to
+// minimize expensive operations such as locking OS calls (File Reads, Network calls, et cetera)
+// License: MIT
+package eagercache
+
+import "time"
+
+type (
+	// MetricsRecorder receives a cache's metrics as they happen, for adapting to Prometheus,
+	// OpenTelemetry, or whatever else, without this package depending on any of them. Each method is
+	// passed the name given by WithName. The methods are called synchronously, sometimes with the
+	// cache's lock held, so they must be fast and must not call back into the cache.
+	MetricsRecorder interface {
+		// RecordHit is called for every Retrieve served from the cache
+		RecordHit(cacheName string)
+		// RecordMiss is called for every Retrieve which had to call the updater
+		RecordMiss(cacheName string)
+		// RecordEvict is called for every entry the cache evicts by itself
+		RecordEvict(cacheName string)
+		// RecordRefreshDuration is called with how long each of the cleaner's refreshes took
+		RecordRefreshDuration(cacheName string, d time.Duration)
+	}
+
+	// noopMetrics is the MetricsRecorder of caches created without WithMetrics
+	noopMetrics struct{}
+)
+
+// WithMetrics has the cache report its hits, misses, evictions and refresh durations to m, in
+// addition to the counters of Stats.
+func WithMetrics(m MetricsRecorder) Option {
+	if m == nil {
+		panic("the metrics recorder must be non-nil")
+	}
+
+	return func(c *Cache) {
+		c.metrics = m
+	}
+}
+
+// WithName names the cache, so it can be told apart from others by a MetricsRecorder
+func WithName(name string) Option {
+	return func(c *Cache) {
+		c.name = name
+	}
+}
+
+// Name returns the name given by WithName, or "" for a cache created without it
+func (c *Cache) Name() string {
+	return c.name
+}
+
+func (noopMetrics) RecordHit(string) {}
+
+func (noopMetrics) RecordMiss(string) {}
+
+func (noopMetrics) RecordEvict(string) {}
+
+func (noopMetrics) RecordRefreshDuration(string, time.Duration) {}
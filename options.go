@@ -0,0 +1,311 @@
+// Package eagercache provides interfaces for threadsafe, mildly-generic, in-memory caches
+// These caches are intended to maximize performance as much as possible by helping to
+// minimize expensive operations such as locking OS calls (File Reads, Network calls, et cetera)
+// License: MIT
+package eagercache
+
+import (
+	"math/rand"
+	"time"
+)
+
+type (
+	// Option customizes a Cache, and is passed to any of the CreateCache constructors
+	Option func(*Cache)
+)
+
+// WithJitter spreads out expirations of entries filled at the same moment, so that they don't all
+// expire, and get refreshed, in the same cleaning pass. Each time an entry's expiry is set, a random
+// offset of up to fraction times the entry's TTL is added to it. That applies both to misses and
+// to the cleaner's refreshes.
+func WithJitter(fraction float64) Option {
+	if fraction < 0 {
+		panic("the jitter fraction must not be negative")
+	}
+
+	return func(c *Cache) {
+		c.jitter = fraction
+		c.rng = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+}
+
+// WithRefreshConcurrency caps how many of the cache's expired entries the cleaner refreshes at once,
+// so a burst of simultaneous expirations can't hammer the updater or its backend. Every refresh still
+// completes within the cleaning pass, just in batches of at most n. The default is GOMAXPROCS.
+func WithRefreshConcurrency(n int) Option {
+	if n < 1 {
+		panic("the refresh concurrency must be at least 1")
+	}
+
+	return func(c *Cache) {
+		c.maxRefreshes = n
+	}
+}
+
+// WithOnEvict registers f to be called whenever an entry leaves the cache: pruned by the cleaner,
+// pushed out by a size limit, or removed with Delete or Flush. Use it to release resources held by
+// values, or to emit metrics. f is called without any lock held, so it may use the cache, but it
+// runs on the goroutine which removed the entry, so it must be fast or hand its work off. Keys known
+// to be absent, see CreateCacheFound, and cached errors hold no value, so f isn't called for them.
+func WithOnEvict(f func(key string, value interface{})) Option {
+	return func(c *Cache) {
+		c.onEvict = f
+	}
+}
+
+// WithOnRefresh registers f to be called after the cleaner replaces an entry's value with a fresh
+// one from the updater. As with WithOnEvict, f is called without any lock held and must be fast or
+// non-blocking, as it holds up the cleaner. A value known to be absent, see CreateCacheFound, or a
+// cached error, is passed to f as nil.
+func WithOnRefresh(f func(key string, oldValue, newValue interface{})) Option {
+	return func(c *Cache) {
+		c.onRefresh = f
+	}
+}
+
+// WithCleanRate has the cleaner scrub the cache every d, instead of at the rate given to StartCleaner.
+// Use it for caches whose expireRate is far shorter or longer than the rest, so that entries are
+// neither served stale for long after expiring nor scrubbed pointlessly often.
+func WithCleanRate(d time.Duration) Option {
+	if d <= 0 {
+		panic("the clean rate must be positive")
+	}
+
+	return func(c *Cache) {
+		c.cleanRate = d
+	}
+}
+
+// WithSlidingExpiration makes every Retrieve of an entry push its expiry back to expireRate from
+// then, so frequently read keys never expire while rarely read ones fall out. An entry which does
+// expire went unread for a whole expireRate, so the cleaner evicts it instead of refreshing it.
+// Every hit has to take the write lock to move the expiry, making hits costlier than usual.
+func WithSlidingExpiration() Option {
+	return func(c *Cache) {
+		c.sliding = true
+	}
+}
+
+// WithLazyEviction turns off eager refreshing, making the cache a conventional TTL cache: the cleaner
+// evicts every expired entry, accessed or not, and an expired entry is never served. The next
+// Retrieve of an evicted key refills it on demand. This trades the latency benefit of eager refresh
+// for fewer backend calls on keys which nobody reads again.
+func WithLazyEviction() Option {
+	return func(c *Cache) {
+		c.lazy = true
+	}
+}
+
+// WithStaleWhileRevalidate has Retrieve serve an entry which expired no more than window ago right
+// away, instead of blocking on the updater, while it's refreshed in the background. Concurrent stale
+// reads of a key share one background refresh. Entries further past their expiry are blocking
+// misses as usual. RetrieveWithStatus reports stale values as StatusStale.
+//
+// Note that entries which have been read since their last fill are always served as-is until the
+// cleaner refreshes them, so the window only matters for those which haven't, and for caches using
+// WithLazyEviction or WithSlidingExpiration.
+func WithStaleWhileRevalidate(window time.Duration) Option {
+	if window < 0 {
+		panic("the stale-while-revalidate window must not be negative")
+	}
+
+	return func(c *Cache) {
+		c.revalidateWindow = window
+	}
+}
+
+// WithInitialCapacity allocates the cache's map with room for n entries, and reallocates it the same
+// way on Flush, so that a cache known to grow large doesn't rehash repeatedly on its way there.
+func WithInitialCapacity(n int) Option {
+	if n < 0 {
+		panic("the initial capacity must not be negative")
+	}
+
+	return func(c *Cache) {
+		c.capacity = n
+	}
+}
+
+// WithRefreshAhead has the cleaner refresh accessed entries which are within lead of their expiry,
+// rather than waiting for them to expire, so that readers of hot keys never find them expired.
+// Entries which haven't been read are still only evicted once they've truly expired. For the lead to
+// take effect, the cache must be scrubbed at least every lead, see WithCleanRate.
+func WithRefreshAhead(lead time.Duration) Option {
+	if lead < 0 {
+		panic("the refresh-ahead lead must not be negative")
+	}
+
+	return func(c *Cache) {
+		c.refreshAhead = lead
+	}
+}
+
+// WithCopyOnRetrieve has the Retrieve family, Peek and RetrieveMany return clone(value) rather than
+// the cached value itself. Without it, every caller is handed the very same value, so when updaters
+// return pointers, maps or slices, one caller's mutation is seen by all of the others and by the
+// cache. The package can't deep-copy arbitrary types, so clone is up to the user. It's called
+// without any lock held, once per value returned, and never on nil values.
+func WithCopyOnRetrieve(clone func(interface{}) interface{}) Option {
+	return func(c *Cache) {
+		c.copyOnRetrieve = clone
+	}
+}
+
+// WithNegativeTTL caches values for which isNotFound returns true, eg: nil or a "missing" marker
+// from an updater whose key doesn't exist upstream, for d rather than the usual TTL. That absorbs
+// repeated lookups of absent keys, without serving them as absent for long after they're created.
+// It applies to misses and to the cleaner's refreshes alike, and takes precedence over the TTLs
+// returned by CreateCacheTTL updaters. isNotFound is called with the cache locked, so it must be fast.
+func WithNegativeTTL(d time.Duration, isNotFound func(interface{}) bool) Option {
+	if d <= 0 {
+		panic("the negative TTL must be positive")
+	}
+
+	if isNotFound == nil {
+		panic("the isNotFound-func must be non-nil")
+	}
+
+	return func(c *Cache) {
+		c.negativeTTL = d
+		c.isNotFound = isNotFound
+	}
+}
+
+// WithoutAccessTracking makes the cache a strictly time-based one, for read-heavy caches which don't
+// need eager refreshing. Hits on unexpired entries are served under the read lock alone, without
+// ever being marked as accessed, so not even the first hit of an interval takes the write lock. As
+// with WithLazyEviction, the cleaner evicts every expired entry and an expired entry is never served.
+// Hits aren't tracked for CreateCacheWithLimit either, so its eviction order becomes first-in,
+// first-out. WithSlidingExpiration, which has to write on every hit, takes precedence over it.
+func WithoutAccessTracking() Option {
+	return func(c *Cache) {
+		c.untracked = true
+	}
+}
+
+// WithServeStale has Retrieve serve an entry which expired no more than maxStaleness ago as-is,
+// instead of blocking on the updater, marking it as accessed so that the cleaner refreshes it on its
+// next pass. Unlike WithStaleWhileRevalidate, no refresh is started by the Retrieve itself. With
+// WithLazyEviction or WithSlidingExpiration, the cleaner evicts the entry instead, and the first
+// Retrieve after that is a blocking miss. RetrieveWithStatus reports stale values as StatusStale.
+//
+// When both are set, WithStaleWhileRevalidate takes precedence within its window, so entries which
+// expired no more than that long ago are refreshed in the background right away, and WithServeStale
+// only applies to those further past their expiry.
+func WithServeStale(maxStaleness time.Duration) Option {
+	if maxStaleness < 0 {
+		panic("the max staleness must not be negative")
+	}
+
+	return func(c *Cache) {
+		c.maxStaleness = maxStaleness
+	}
+}
+
+// WithMaxAge bounds how long an entry lives, however often it's read: once d has passed since the
+// updater filled it on a miss, or since it was Set, the cleaner evicts it rather than refreshing it,
+// and it's never served again, so the next Retrieve is a cold miss. Use it for data which must be
+// fully re-derived now and then regardless of access, eg: to pick up schema changes. Pinned entries
+// are exempt.
+func WithMaxAge(d time.Duration) Option {
+	if d <= 0 {
+		panic("the max age must be positive")
+	}
+
+	return func(c *Cache) {
+		c.maxAge = d
+	}
+}
+
+// WithoutPool keeps the cache from being registered with the background cleaner, for short-lived
+// caches, eg: request-scoped ones, which aren't worth scrubbing. Nothing is refreshed eagerly, so
+// like WithLazyEviction, an expired entry is never served, and the next Retrieve of it refills it.
+// Nothing is evicted by expiry either, so the cache only shrinks on Delete, Flush, size limits, or
+// RunScrubNow, which scrubs it on demand. Implode still releases its entries.
+func WithoutPool() Option {
+	return func(c *Cache) {
+		c.unpooled = true
+	}
+}
+
+// WithRefreshTimeout bounds the cleaner's refreshes of the cache: each cleaning pass gives its
+// updater calls d in all, through the context passed to CreateCacheCtx updaters, after which the
+// calls still running are abandoned, and the cache's lock released, even if the updater ignores the
+// context. An abandoned call runs on in its own goroutine, and its result is discarded. Entries whose
+// refresh was abandoned are kept, and served stale, unless WithEvictOnRefreshTimeout is also set.
+// It bounds RefreshMatching the same way.
+func WithRefreshTimeout(d time.Duration) Option {
+	if d <= 0 {
+		panic("the refresh timeout must be positive")
+	}
+
+	return func(c *Cache) {
+		c.refreshTimeout = d
+	}
+}
+
+// WithEvictOnRefreshTimeout has the cleaner evict the entries whose refresh WithRefreshTimeout
+// abandoned, rather than keep serving them stale, so their next Retrieve is a blocking miss.
+func WithEvictOnRefreshTimeout() Option {
+	return func(c *Cache) {
+		c.evictOnRefreshTimeout = true
+	}
+}
+
+// WithRefreshBackoff decides the fate of entries whose refresh by the cleaner fails, by an error or a
+// panic: they keep their last good value, which is served as usual, and their expiry is pushed back
+// to d from the failed attempt, so that the cleaner retries them once d is up, rather than on every
+// pass. That way a flapping backend neither loses the cached data nor gets hammered with retries.
+// Without it, failed entries still keep their value, but are retried on every pass.
+func WithRefreshBackoff(d time.Duration) Option {
+	if d <= 0 {
+		panic("the refresh backoff must be positive")
+	}
+
+	return func(c *Cache) {
+		c.refreshBackoff = d
+	}
+}
+
+// WithEqualityFunc has the cleaner compare each refreshed value to the one it replaces with equal,
+// and keep the stored value when they're equal, only pushing its expiry back, eg: for updaters which
+// mostly return the same data. Refreshes which changed nothing don't trigger OnRefresh nor an
+// EventRefresh, which saves subscribers from invalidating what's still valid. equal is called from
+// the cleaner's refresh goroutines, so it must be threadsafe. Without it, every refresh replaces the
+// stored value.
+func WithEqualityFunc(equal func(oldValue, newValue interface{}) bool) Option {
+	if equal == nil {
+		panic("the equality func must be a non-nil func(interface{}, interface{}) bool")
+	}
+
+	return func(c *Cache) {
+		c.equalityFunc = equal
+	}
+}
+
+// WithMinRefreshInterval keeps the cleaner from refreshing an entry more than once every d, however
+// often the entry expires and is read, eg: with a short clean rate or expireRate on data which barely
+// changes. An entry which is due for a refresh before d has passed since its last one keeps serving
+// its current value, and is refreshed on the first scrub after that.
+func WithMinRefreshInterval(d time.Duration) Option {
+	if d <= 0 {
+		panic("the min refresh interval must be positive")
+	}
+
+	return func(c *Cache) {
+		c.minRefreshInterval = d
+	}
+}
+
+// WithInitialKeys fills keys through the updater as the cache is created, for caches whose keyspace is
+// small and known up front, so that none of them ever miss. It's WarmUp, called by the constructor:
+// the fills run concurrently, under the WithRefreshConcurrency limit, and keys whose fill failed are
+// left to be filled by their first Retrieve. With wait set, the constructor returns once every key is
+// filled, otherwise the fills run in the background, and a Retrieve which beats its key's fill shares
+// the updater call with it.
+func WithInitialKeys(keys []string, wait bool) Option {
+	return func(c *Cache) {
+		c.initialKeys = keys
+		c.waitInitial = wait
+	}
+}
@@ -0,0 +1,72 @@
+package eagercache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+// BenchmarkFillInitialCapacity fills 50k keys into a fresh cache per op, with and without room for
+// them up front, see WithInitialCapacity
+func BenchmarkFillInitialCapacity(b *testing.B) {
+	const n = 50000
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+
+	updater := func(key string) interface{} { return key }
+	for _, bc := range []struct {
+		name string
+		opts []Option
+	}{
+		{"without", []Option{WithoutPool()}},
+		{"with", []Option{WithoutPool(), WithInitialCapacity(n)}},
+	} {
+		opts := bc.opts
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				c := CreateCache(time.Minute, updater, opts...)
+				for _, key := range keys {
+					c.Set(key, key)
+				}
+			}
+		})
+	}
+}
+
+func TestRetrieveAliasesValues(t *testing.T) {
+	c := CreateCache(time.Minute, func(key string) interface{} {
+		return map[string]int{"n": 1}
+	}, WithoutPool())
+
+	// every caller is handed the cached map itself, so a mutation is seen by all of them
+	c.Retrieve("k").(map[string]int)["n"] = 2
+	if n := c.Retrieve("k").(map[string]int)["n"]; n != 2 {
+		t.Fatalf("n = %d, want the mutation seen through the shared value", n)
+	}
+}
+
+func TestWithCopyOnRetrieve(t *testing.T) {
+	c := CreateCache(time.Minute, func(key string) interface{} {
+		return map[string]int{"n": 1}
+	}, WithoutPool(), WithCopyOnRetrieve(func(v interface{}) interface{} {
+		clone := map[string]int{}
+		for k, n := range v.(map[string]int) {
+			clone[k] = n
+		}
+		return clone
+	}))
+
+	c.Retrieve("k").(map[string]int)["n"] = 2
+	if n := c.Retrieve("k").(map[string]int)["n"]; n != 1 {
+		t.Errorf("Retrieve: n = %d, want the cached value untouched by a caller's mutation", n)
+	}
+
+	v, _ := c.Peek("k")
+	v.(map[string]int)["n"] = 3
+	if n := c.RetrieveMany([]string{"k"})["k"].(map[string]int)["n"]; n != 1 {
+		t.Errorf("RetrieveMany: n = %d, want the cached value untouched by a caller's mutation", n)
+	}
+}
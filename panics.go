@@ -0,0 +1,71 @@
+// Package eagercache provides interfaces for threadsafe, mildly-generic, in-memory caches
+// These caches are intended to maximize performance as much as possible by helping to
+// minimize expensive operations such as locking OS calls (File Reads, Network calls, et cetera)
+// License: MIT
+package eagercache
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type (
+	// PanicError is the error a panicking updater call is turned into. Like any other updater
+	// error, nothing is stored and the previous entry for Key, if any, is left as it was.
+	PanicError struct {
+		Key       string
+		Recovered interface{}
+	}
+)
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("eagercache: the updater panicked for key %q: %v", e.Key, e.Recovered)
+}
+
+// WithPanicHandler registers f to be told whenever the updater panics, eg: to log it. Panics are
+// recovered either way, but without a handler the only trace of one is the *PanicError returned by
+// the error-returning methods such as RetrieveCtx.
+func WithPanicHandler(f func(key string, recovered interface{})) Option {
+	return func(c *Cache) {
+		c.onPanic = f
+	}
+}
+
+// safeLoad calls load for key, recovering a panic into a *PanicError so a misbehaving updater can't
+// crash the goroutine calling Retrieve, nor the cleaner, and counts its outcome for the circuit
+// breaker
+func (c *Cache) safeLoad(ctx context.Context, load loadFunc, key string) (interface{}, time.Duration, error) {
+	if !c.breaker.allow(c.clock.Now()) {
+		return nil, 0, ErrCircuitOpen
+	}
+
+	v, ttl, err := c.recoveredLoad(ctx, load, key)
+	if !callerDone(ctx) {
+		c.breaker.record(c.clock.Now(), err)
+	}
+
+	return v, ttl, err
+}
+
+// recoveredLoad is safeLoad without the circuit breaker, for callers which count the outcome
+// themselves
+func (c *Cache) recoveredLoad(ctx context.Context, load loadFunc, key string) (v interface{}, ttl time.Duration, err error) {
+	start := time.Now()
+	defer func() {
+		c.stats.latency.record(time.Since(start))
+
+		if r := recover(); r != nil {
+			v, ttl, err = nil, 0, &PanicError{Key: key, Recovered: r}
+			if c.onPanic != nil {
+				c.onPanic(key, r)
+			}
+		}
+
+		if err != nil && c.logger != nil {
+			c.logger.Log(LogError, "updater failed", "cache", c.name, "key", key, "err", err)
+		}
+	}()
+
+	return load(ctx, key)
+}
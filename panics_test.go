@@ -0,0 +1,86 @@
+package eagercache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPanickingUpdaterOnMiss(t *testing.T) {
+	var handled atomic.Int32
+	c := CreateCache(time.Minute, func(key string) interface{} {
+		panic("boom")
+	}, WithoutPool(), WithPanicHandler(func(key string, recovered interface{}) {
+		handled.Add(1)
+	}))
+
+	if v := c.Retrieve("k"); v != nil {
+		t.Errorf("Retrieve(k) = %v, want nil from a panicking updater", v)
+	}
+
+	_, err := c.RetrieveCtx(context.Background(), "k")
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) || panicErr.Key != "k" || panicErr.Recovered != "boom" {
+		t.Errorf("RetrieveCtx(k) = %v, want a *PanicError for k", err)
+	}
+
+	if n := handled.Load(); n != 2 {
+		t.Errorf("the panic handler was called %d times, want 2", n)
+	}
+
+	if n := c.Len(); n != 0 {
+		t.Errorf("Len() = %d, want nothing stored", n)
+	}
+}
+
+func TestCleanerSurvivesPanickingUpdater(t *testing.T) {
+	StartCleaner(time.Millisecond)
+	defer StopCleaner()
+
+	clk := newFakeClock()
+	panicked := make(chan struct{}, 1)
+	var calls atomic.Int32
+	bad := CreateCache(time.Minute, func(key string) interface{} {
+		if calls.Add(1) > 1 {
+			panic("boom")
+		}
+		return key
+	}, WithClock(clk), WithPanicHandler(func(key string, recovered interface{}) {
+		select {
+		case panicked <- struct{}{}:
+		default:
+		}
+	}))
+	defer bad.Implode()
+
+	// a callback panicking outside of the updater is recovered by the cleaner too
+	clumsy := CreateCache(time.Minute, func(key string) interface{} { return key },
+		WithClock(clk), WithOnEvict(func(key string, value interface{}) {
+			panic("boom")
+		}))
+	defer clumsy.Implode()
+
+	bad.Retrieve("k")
+	clumsy.Set("k", "v")
+	clk.advance(2 * time.Minute)
+
+	select {
+	case <-panicked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the cleaner never refreshed the panicking cache")
+	}
+
+	eventually(t, "the cleaner never evicted the entry with a panicking OnEvict", func() bool {
+		return clumsy.Len() == 0
+	})
+
+	// expired, but accessed, so it's served as it was while the refreshes keep failing
+	if v := bad.Retrieve("k"); v != "k" {
+		t.Errorf("Retrieve(k) = %v, want the entry left as it was by the failed refresh", v)
+	}
+
+	// the cleaner goes on cleaning every cache
+	awaitScrub(t)
+}
@@ -0,0 +1,40 @@
+// Package eagercache provides interfaces for threadsafe, mildly-generic, in-memory caches
+// These caches are intended to maximize performance as much as possible by helping to
+// minimize expensive operations such as locking OS calls (File Reads, Network calls, et cetera)
+// License: MIT
+package eagercache
+
+// PauseRefresh stops the cleaner from calling the updater for the cache, eg: to take the load off a
+// backend during an incident. While paused, expired entries which were accessed aren't refreshed,
+// they're left as they are, and keep being served, with their last value, by Retrieve, while the
+// rest are evicted at expiry as usual. WithRefreshAhead's early refreshes are skipped too. Misses
+// still call the updater, so paused caches only ever reach the backend for keys they don't hold.
+// The refreshes resume on the cleaner's first pass after ResumeRefresh.
+func (c *Cache) PauseRefresh() {
+	c.mu.Lock()
+	c.refreshPaused = true
+	c.mu.Unlock()
+}
+
+// ResumeRefresh undoes PauseRefresh. On its next pass, the cleaner refreshes every accessed entry
+// which expired in the meantime, under the usual WithRefreshConcurrency limit.
+func (c *Cache) ResumeRefresh() {
+	c.mu.Lock()
+	c.refreshPaused = false
+	c.mu.Unlock()
+}
+
+// PauseAllRefresh calls PauseRefresh on every Cache returned by AllCaches. Caches created while
+// paused aren't paused, and ResumeAllRefresh also resumes those which were paused on their own.
+func PauseAllRefresh() {
+	for _, c := range AllCaches() {
+		c.PauseRefresh()
+	}
+}
+
+// ResumeAllRefresh calls ResumeRefresh on every live cache, see PauseAllRefresh
+func ResumeAllRefresh() {
+	for _, c := range AllCaches() {
+		c.ResumeRefresh()
+	}
+}
@@ -0,0 +1,66 @@
+// Package eagercache provides interfaces for threadsafe, mildly-generic, in-memory caches
+// These caches are intended to maximize performance as much as possible by helping to
+// minimize expensive operations such as locking OS calls (File Reads, Network calls, et cetera)
+// License: MIT
+package eagercache
+
+import "time"
+
+// pinnedExpiry is the expiresAt of pinned entries. Being far beyond any real expiry, every expiry
+// check treats pinned entries as unexpired, without having to know about pinning.
+var pinnedExpiry = time.Unix(1<<62, 0)
+
+// pinned reports whether the entry was stored by Pin, and so never expires
+func (e expirable) pinned() bool {
+	return e.expiresAt.Equal(pinnedExpiry)
+}
+
+// Pin stores value for key, replacing any entry already there, as an entry which never expires:
+// the cleaner neither evicts nor refreshes it, for values which are costly to compute and don't
+// change for the life of the process. Pinned entries are returned by Retrieve, and counted by Len
+// and Keys, like any other. A later Set or Import of key stores it as a regular entry again, as does
+// Unpin. Pinned entries still count towards the limits of CreateCacheWithLimit and
+// CreateCacheWithByteLimit, and may be evicted to stay within them. Pin is a no-op on an imploded
+// cache.
+func (c *Cache) Pin(key string, value interface{}) {
+	c.validate(key, value)
+	key = c.storedKey(key)
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return
+	}
+
+	entry := c.data[key]
+	entry.value = value
+	entry.expiresAt = pinnedExpiry
+	entry.createdAt = c.clock.Now()
+	entry.version = c.nextVersionLocked()
+
+	evicted := c.touchLocked(key, &entry)
+	c.data[key] = entry
+	c.mu.Unlock()
+
+	c.notifyEvicted(evicted)
+}
+
+// Unpin returns the pinned entry for key to the regular expiry, a full expireRate from now. Under
+// WithMaxAge, its age counts from the Unpin too, rather than from the Pin. It's a no-op if key isn't
+// pinned.
+func (c *Cache) Unpin(key string) {
+	key = c.storedKey(key)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.data[key]
+	if !ok || !entry.pinned() {
+		return
+	}
+
+	n := c.clock.Now()
+	entry.expiresAt = c.expiresFrom(n, entry.value, 0)
+	entry.createdAt = n
+	c.data[key] = entry
+}
@@ -0,0 +1,21 @@
+package eagercache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUnpinUnderMaxAge(t *testing.T) {
+	clk := newFakeClock()
+	c := CreateCache(time.Minute, func(key string) interface{} { return "filled" },
+		WithoutPool(), WithClock(clk), WithMaxAge(time.Hour))
+
+	c.Pin("k", "pinned")
+	clk.advance(2 * time.Hour)
+	c.Unpin("k")
+	c.RunScrubNow()
+
+	if v, ok := c.Peek("k"); !ok || v != "pinned" {
+		t.Fatalf("Peek(k) = %v, %v after Unpin, want the pinned value, not yet too old", v, ok)
+	}
+}
@@ -0,0 +1,82 @@
+// Package eagercache provides interfaces for threadsafe, mildly-generic, in-memory caches
+// These caches are intended to maximize performance as much as possible by helping to
+// minimize expensive operations such as locking OS calls (File Reads, Network calls, et cetera)
+// License: MIT
+package eagercache
+
+import "time"
+
+type (
+	// Decision is what a WithEvictionPolicy policy tells the cleaner to do with an entry
+	Decision int
+
+	// EntryInfo describes an entry to a WithEvictionPolicy policy
+	EntryInfo struct {
+		// Age is how long ago the entry was filled, not counting the cleaner's refreshes
+		Age time.Duration
+		// SinceExpiry is how long ago the entry expired, negative for an entry yet to expire
+		SinceExpiry time.Duration
+		// Accessed is whether the entry was read since it was filled or last refreshed
+		Accessed bool
+		// Value is the cached value, which the policy must not modify
+		Value interface{}
+	}
+)
+
+const (
+	// DecisionKeep leaves the entry as it is, until the cleaner's next pass
+	DecisionKeep Decision = iota
+	// DecisionEvict removes the entry, exactly as the cleaner evicts expired entries
+	DecisionEvict
+	// DecisionRefresh calls the updater to replace the entry's value, exactly as the cleaner
+	// refreshes expired entries which were accessed
+	DecisionRefresh
+)
+
+func (d Decision) String() string {
+	switch d {
+	case DecisionKeep:
+		return "keep"
+	case DecisionEvict:
+		return "evict"
+	case DecisionRefresh:
+		return "refresh"
+	default:
+		return "unknown"
+	}
+}
+
+// WithEvictionPolicy hands every decision of the cleaner to policy, eg: to keep expensive values
+// around past their expiry. On each scrub, the cleaner calls policy for each of the cache's entries,
+// expired or not, and does whatever it decides instead of the usual evicting or refreshing at
+// expiry, so a policy will usually keep unexpired entries. Pinned entries are left out, and
+// refreshes are downgraded to keeps while PauseRefresh is in effect.
+//
+// policy is called under the cache's write lock, so it must be fast, and mustn't call back into the
+// cache. Expired entries which it keeps are served, or refilled on Retrieve, exactly as they would be
+// while awaiting the cleaner.
+func WithEvictionPolicy(policy func(key string, e EntryInfo) Decision) Option {
+	if policy == nil {
+		panic("the eviction policy must be a non-nil func(string, EntryInfo) Decision")
+	}
+
+	return func(c *Cache) {
+		c.evictionPolicy = policy
+	}
+}
+
+// entryInfo describes entry at n to the eviction policy
+func entryInfo(entry expirable, n time.Time) EntryInfo {
+	info := EntryInfo{
+		Age:         n.Sub(entry.createdAt),
+		SinceExpiry: n.Sub(entry.expiresAt),
+		Accessed:    entry.wasAccessedInInterval,
+		Value:       entry.value,
+	}
+
+	if isAbsent(info.Value) {
+		info.Value = nil
+	}
+
+	return info
+}
@@ -5,7 +5,10 @@
 package eagercache
 
 import (
+	"container/list"
+	"math/rand"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -15,69 +18,389 @@ type (
 		mu        sync.RWMutex
 		cleanRate time.Duration
 
-		// pointers for concurrent accesses
-		pool []*Cache
+		// every live cache, keyed by its pointer. removeCache deletes from it outright, so however many
+		// caches come and go, it only ever holds, and the scrubber only ever walks, the live ones.
+		// A Cache is held by its state rather than by the *Cache, so that a cache created WithFinalizer
+		// becomes unreachable once its user drops it, see cacheState.
+		pool map[pooled]*scrubSchedule
+		// wake interrupts the scrubber's sleep when the schedule changes, eg: a new cache is added
+		wake chan struct{}
+		// clock is what the scrubber tells the time and sleeps by, only ever replaced by tests
+		clock scrubClock
+		// lastRun and nextRun are when the scrubber last started a pass, and when it plans to start the
+		// next one. running is whether it's running at all. See CleanerStatus.
+		lastRun, nextRun time.Time
+		running          bool
+		// generation counts the scrubbers launched, only the latest of which reports whether it's
+		// running, as one which Shutdown gave up on may still be finishing its scrubs
+		generation uint64
+
+		// launchMu guards scrubberLauncher, stop and done across StartCleaner/StopCleaner
+		launchMu sync.Mutex
+		// stop is closed to ask the scrubber to exit, done is closed by the scrubber once it has
+		stop chan struct{}
+		done chan struct{}
+	}
+
+	// scrubSchedule tracks when a pooled cache was last scrubbed, and whether it's being scrubbed now
+	scrubSchedule struct {
+		last time.Time
+		busy bool
+	}
+
+	// pooled is implemented by every cache type the cachePooler keeps clean
+	pooled interface {
+		processExpired()
+		// scrubRate is how often the cache wants scrubbing, or 0 for the pool's cleanRate
+		scrubRate() time.Duration
 	}
 
 	// expirable encapsulates cache entries and indicate when it should expire
 	expirable struct {
 		wasAccessedInInterval bool
 		expiresAt             time.Time
-		value                 interface{}
+		// createdAt is when the entry was last filled other than by a refresh, see WithMaxAge
+		createdAt time.Time
+		value     interface{}
+		// elem is the entry's node in Cache.lru, nil for caches without a size limit
+		elem *list.Element
+		// size is what sizeOf estimated value to take, 0 for caches without a byte limit
+		size int64
+		// version orders the values stored in the cache, see RetrieveVersioned
+		version uint64
+		// lastRefresh is when the cleaner last refreshed the entry, see WithMinRefreshInterval
+		lastRefresh time.Time
+	}
+
+	// refresh is the outcome of one background updater call made by processExpired. ok is false
+	// when the updater failed, and the previous entry has to be kept.
+	refresh struct {
+		key   string
+		entry expirable
+		old   interface{}
+		ok    bool
+		// ttl is the updater-provided ttl of the refreshed entry, whose expiry is set from it
+		ttl time.Duration
+		// timedOut is set when the updater call was abandoned, see WithRefreshTimeout
+		timedOut bool
+		// unchanged is set when the refreshed value equals the old one, see WithEqualityFunc
+		unchanged bool
+	}
+
+	// removal is an entry which left the cache, held until the OnEvict callback can be called
+	removal struct {
+		key     string
+		value   interface{}
+		version uint64
 	}
 
 	// Cache is the implementation of the cache mechanism
 	Cache struct {
+		*cacheState
+	}
+
+	// cacheState is everything a Cache holds. The pool holds the state, not the *Cache handle on it,
+	// so that only the users of a cache can keep the handle reachable, see WithFinalizer.
+	cacheState struct {
+		// handle is the Cache the state belongs to, nil for caches created WithFinalizer, whose
+		// handle the pool mustn't keep reachable
+		handle *Cache
+		// finalize is set by WithFinalizer
+		finalize bool
+
+		name       string
+		metrics    MetricsRecorder
+		logger     Logger
+		clock      Clock
 		mu         *sync.RWMutex
+		closed     bool
 		expireRate time.Duration
 		data       map[string]expirable
-		updater    EntryUpdater
-		poolIndex  int
+		updater    loadFunc
+		stats      cacheStats
+		events     eventHub
+		flights    flightGroup
+
+		// version is the last version stamped on a stored value, see nextVersionLocked
+		version uint64
+		// refreshPaused stops the cleaner from refreshing entries, see PauseRefresh
+		refreshPaused bool
+		// breaker skips the updater while it's failing, nil unless WithCircuitBreaker is used
+		breaker *circuitBreaker
+		// evictionPolicy replaces the cleaner's own decisions when set, see WithEvictionPolicy
+		evictionPolicy func(key string, e EntryInfo) Decision
+		// equal compares refreshed values to the ones they'd replace, see WithEqualityFunc
+		equalityFunc func(oldValue, newValue interface{}) bool
+		// minRefreshInterval is how long the cleaner waits between refreshes of an entry, see
+		// WithMinRefreshInterval
+		minRefreshInterval time.Duration
+		// initialKeys are warmed up by the constructor, waiting for them if waitInitial is set, see
+		// WithInitialKeys
+		initialKeys []string
+		waitInitial bool
+		// errorTTL is how long failed fills are cached for, see WithErrorCaching
+		errorTTL time.Duration
+
+		// batchFill is the updater of a CreateCacheBatchFill cache, used on misses
+		batchFill func(key string) map[string]interface{}
+
+		// capacity is the size hint data is allocated with, see WithInitialCapacity
+		capacity int
+
+		// maxEntries caps len(data) when non-zero. lru orders the keys from most to least recently
+		// used so the cap can be enforced; it's nil for unlimited caches.
+		maxEntries int
+		lru        *list.List
+
+		// maxBytes caps usedBytes when non-zero, using the same lru order as maxEntries. usedBytes is
+		// the sum of every entry's size, as estimated by sizeOf.
+		maxBytes  int64
+		usedBytes atomic.Int64
+		sizeOf    func(interface{}) int64
+
+		// jitter is the fraction of an entry's TTL randomly added on top of it, see WithJitter.
+		// rng is private to the cache, guarded by rngMu, so jitter doesn't contend on math/rand's lock.
+		jitter float64
+		rngMu  sync.Mutex
+		rng    *rand.Rand
+
+		// maxRefreshes bounds the concurrent updater calls of a cleaning pass, see WithRefreshConcurrency
+		maxRefreshes int
+		// sliding pushes an entry's expiry back on every Retrieve, see WithSlidingExpiration
+		sliding bool
+		// revalidateWindow is how long past expiry an entry is served stale, see WithStaleWhileRevalidate
+		revalidateWindow time.Duration
+		// maxAge is how long an entry may be refreshed for before being evicted, see WithMaxAge
+		maxAge time.Duration
+		// maxStaleness is how long past expiry an entry is served as-is, see WithServeStale
+		maxStaleness time.Duration
+		// lazy evicts every expired entry instead of refreshing accessed ones, see WithLazyEviction
+		lazy bool
+		// unpooled caches aren't registered with the cleaner, see WithoutPool
+		unpooled bool
+		// untracked never marks entries as accessed on hits, see WithoutAccessTracking
+		untracked bool
+		// copyOnRetrieve clones values on their way out to callers, see WithCopyOnRetrieve
+		copyOnRetrieve func(interface{}) interface{}
+		// negativeTTL is how long values isNotFound matches are cached for, see WithNegativeTTL
+		negativeTTL time.Duration
+		isNotFound  func(interface{}) bool
+		// tracksFound is set for caches which store absentValues or cachedErrors, see CreateCacheFound
+		// and WithErrorCaching
+		tracksFound bool
+		// refreshTimeout bounds each cleaning pass's refreshes, see WithRefreshTimeout
+		refreshTimeout        time.Duration
+		evictOnRefreshTimeout bool
+		// refreshBackoff is how long a failed refresh is retried after, see WithRefreshBackoff
+		refreshBackoff time.Duration
+		// refreshAhead is how long before expiry accessed entries are refreshed, see WithRefreshAhead
+		refreshAhead time.Duration
+		// validatePointers checks stored values are pointers, see WithPointerValidation
+		validatePointers bool
+		onInvalid        func(key string, err error)
+		// onPanic is told about updater panics, see WithPanicHandler
+		onPanic func(key string, recovered interface{})
+		// cleanRate overrides the pool's cleanRate for this cache when non-zero, see WithCleanRate
+		cleanRate time.Duration
+
+		// optional callbacks, see WithOnEvict and WithOnRefresh
+		onEvict   func(key string, value interface{})
+		onRefresh func(key string, oldValue, newValue interface{})
+
+		// secondary is where evicted entries spill to, encoded by marshal, see WithSecondaryStore
+		secondary SecondaryStore
+		marshal   func(interface{}) ([]byte, error)
+		unmarshal func([]byte) (interface{}, error)
+		// secondaryFloors invalidate what was spilled before a DeletePrefix or Flush. spills counts
+		// the values spilled, the oldest of which had version oldestSpill.
+		secondaryFloors []secondaryFloor
+		spills          uint64
+		oldestSpill     uint64
+
+		// keyHasher maps keys to the keys their entries are stored under, see WithKeyHasher
+		keyHasher func(key string) string
 	}
 )
 
+// maxConcurrentScrubs bounds how many caches the scrubber processes at once. Scrubs mostly wait on
+// updaters rather than burn CPU, so it isn't tied to GOMAXPROCS.
+const maxConcurrentScrubs = 16
+
 var (
 	p = cachePooler{
 		cleanRate: 2 * time.Minute,
+		pool:      map[pooled]*scrubSchedule{},
+		wake:      make(chan struct{}, 1),
+		clock:     realClock{},
 	}
 
 	scrubberLauncher = new(sync.Once)
 )
 
-func scrubber() {
+func scrubber(generation uint64, stop <-chan struct{}, done chan<- struct{}) {
+	// Caches are scrubbed concurrently, so one with a slow updater can't hold up the rest, but only
+	// by so many workers at once. Stopping waits for the scrubs already underway.
+	var wg sync.WaitGroup
+	workers := make(chan struct{}, maxConcurrentScrubs)
+	p.setRunning(generation, true)
+	defer func() {
+		wg.Wait()
+		p.setRunning(generation, false)
+		close(done)
+	}()
+
 	for {
 		// Initiate the cache cleans on arbitrary intervals
 		// Slow cleaning in order to avoid burning CPU cycles to the garbage collector
-		time.Sleep(p.cleanRate)
-		p.mu.RLock()
-		for i := 0; i < len(p.pool); i++ {
-			if (p.pool)[i] == nil {
-				continue
-			}
-			(p.pool)[i].processExpired()
+		fire, stopTimer := p.clock.NewTimer(p.untilNextScrub(p.clock.Now()))
+
+		select {
+		case <-stop:
+			stopTimer()
+			return
+		case <-p.wake:
+			stopTimer()
+			continue
+		case <-fire:
+		}
+
+		// the caches are scrubbed without the pool lock, so that Implode never waits on a scrub
+		for _, c := range p.takeDue(p.clock.Now()) {
+			workers <- struct{}{}
+			wg.Add(1)
+			go func(c pooled) {
+				defer func() {
+					p.scrubbed(c)
+					<-workers
+					wg.Done()
+				}()
+
+				scrub(c)
+			}(c)
 		}
-		p.mu.RUnlock()
 	}
 }
 
-func (cp *cachePooler) addCache(c *Cache) {
+// scrub processes c's expired entries, recovering any panic so that one misbehaving cache, eg: from
+// an OnEvict callback, can't stop the cleaning of every other cache
+func scrub(c pooled) {
+	defer func() {
+		_ = recover()
+	}()
+
+	c.processExpired()
+}
+
+// untilNextScrub returns how long the scrubber can sleep before some cache is due for scrubbing,
+// recording when that is for CleanerStatus
+func (cp *cachePooler) untilNextScrub(n time.Time) (next time.Duration) {
 	cp.mu.Lock()
-	c.poolIndex = len(cp.pool)
-	cp.pool = append(cp.pool, c)
+	defer func() {
+		cp.nextRun = n.Add(next)
+		cp.mu.Unlock()
+	}()
+
+	next = cp.cleanRate
+	for c, s := range cp.pool {
+		if s.busy {
+			continue
+		}
+
+		if d := s.last.Add(cp.rateOf(c)).Sub(n); d < next {
+			next = d
+		}
+	}
+
+	if next < 0 {
+		return 0
+	}
+
+	return next
+}
+
+// takeDue returns every cache due for scrubbing at n, which isn't still being scrubbed from an
+// earlier pass, and marks them as being scrubbed as of n
+func (cp *cachePooler) takeDue(n time.Time) []pooled {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	cp.lastRun = n
+
+	var due []pooled
+	for c, s := range cp.pool {
+		if s.busy || s.last.Add(cp.rateOf(c)).After(n) {
+			continue
+		}
+
+		s.last = n
+		s.busy = true
+		due = append(due, c)
+	}
+
+	return due
+}
+
+// setRunning records whether the scrubber of generation is running, for CleanerStatus, unless a
+// later scrubber has been launched since
+func (cp *cachePooler) setRunning(generation uint64, running bool) {
+	cp.mu.Lock()
+	if cp.generation == generation {
+		cp.running = running
+	}
 	cp.mu.Unlock()
 }
 
-func (cp *cachePooler) removeCache(c *Cache) {
-	if c == nil || c.poolIndex < 0 {
-		return
+// scrubbed marks c as done being scrubbed, and gets the scrubber to reschedule it
+func (cp *cachePooler) scrubbed(c pooled) {
+	cp.mu.Lock()
+	if s, ok := cp.pool[c]; ok {
+		s.busy = false
+	}
+	cp.mu.Unlock()
+
+	cp.wakeScrubber()
+}
+
+// rateOf is how often c gets scrubbed. The caller must hold cp.mu.
+func (cp *cachePooler) rateOf(c pooled) time.Duration {
+	if r := c.scrubRate(); r > 0 {
+		return r
 	}
 
-	p.mu.Lock()
-	if cp.pool[c.poolIndex] != c {
-		panic("cache poolIndex doesn't map to the same pointer as was passed in")
+	return cp.cleanRate
+}
+
+// wakeScrubber gets the scrubber to recompute its schedule, without blocking
+func (cp *cachePooler) wakeScrubber() {
+	select {
+	case cp.wake <- struct{}{}:
+	default:
 	}
+}
+
+func (cp *cachePooler) addCache(c pooled) {
+	cp.mu.Lock()
+	cp.pool[c] = &scrubSchedule{last: cp.clock.Now()}
+	cp.mu.Unlock()
+
+	cp.wakeScrubber()
+}
+
+func (cp *cachePooler) removeCache(c pooled) {
+	if c == nil {
+		return
+	}
+
+	cp.mu.Lock()
+	delete(cp.pool, c)
+	cp.mu.Unlock()
+}
+
+func (s *cacheState) scrubRate() time.Duration {
+	return s.cleanRate
+}
 
-	cp.pool[c.poolIndex] = nil
-	c.poolIndex = -1
-	p.mu.Unlock()
+// processExpired scrubs the cache on behalf of the pool, see Cache.processExpired
+func (s *cacheState) processExpired() {
+	s.cache().processExpired()
 }
@@ -0,0 +1,238 @@
+package eagercache
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// eventually polls cond until it holds, failing the test with msg if it doesn't within a few seconds
+func eventually(t *testing.T, msg string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal(msg)
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// poolSize returns how many caches are registered with the pool
+func poolSize() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return len(p.pool)
+}
+
+func TestPoolStaysBoundedUnderChurn(t *testing.T) {
+	before := poolSize()
+
+	for i := 0; i < 10000; i++ {
+		c := CreateCache(time.Minute, func(key string) interface{} { return key })
+		c.Retrieve("k")
+		c.Implode()
+	}
+
+	if after := poolSize(); after > before {
+		t.Fatalf("len(p.pool) grew from %d to %d over 10000 imploded caches", before, after)
+	}
+}
+
+// awaitScrub has the running cleaner scrub a cache of its own, and waits for it to
+func awaitScrub(t *testing.T) {
+	t.Helper()
+
+	clk := newFakeClock()
+	c := CreateCache(time.Minute, func(key string) interface{} { return key }, WithClock(clk))
+	defer c.Implode()
+
+	c.Set("k", "v")
+	clk.advance(2 * time.Minute)
+	eventually(t, "the cleaner never evicted the expired entry", func() bool {
+		return c.Len() == 0
+	})
+}
+
+func TestStartStopCleaner(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	StartCleaner(time.Millisecond)
+	awaitScrub(t)
+	StopCleaner()
+
+	if _, _, running := CleanerStatus(); running {
+		t.Fatal("the cleaner still runs after StopCleaner returned")
+	}
+
+	// restarting launches a fresh scrubber, and stopping twice is fine
+	StartCleaner(time.Millisecond)
+	awaitScrub(t)
+	StopCleaner()
+	StopCleaner()
+
+	eventually(t, "StopCleaner leaked the scrubber goroutine", func() bool {
+		return runtime.NumGoroutine() <= before
+	})
+}
+
+func TestPoolChurnWhileScrubbing(t *testing.T) {
+	StartCleaner(time.Millisecond)
+	defer StopCleaner()
+
+	before := poolSize()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				c := CreateCache(time.Minute, func(key string) interface{} { return key })
+				c.Retrieve("k")
+				c.Implode()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if after := poolSize(); after > before {
+		t.Fatalf("len(p.pool) grew from %d to %d over 4000 imploded caches", before, after)
+	}
+
+	// the caches still registered keep getting scrubbed
+	awaitScrub(t)
+}
+
+func TestSlowCacheDoesntHoldUpCleaner(t *testing.T) {
+	StartCleaner(time.Millisecond)
+	defer StopCleaner()
+
+	// the slow cache's refresh blocks until the fast caches have all been cleaned
+	slowClk := newFakeClock()
+	entered, release := make(chan struct{}), make(chan struct{})
+	var calls int32
+	var once sync.Once
+	slow := CreateCache(time.Minute, func(key string) interface{} {
+		if calls++; calls > 1 {
+			once.Do(func() { close(entered) })
+			<-release
+		}
+		return key
+	}, WithClock(slowClk))
+	defer slow.Implode()
+	defer close(release)
+
+	slow.Retrieve("k")
+	slowClk.advance(2 * time.Minute)
+
+	select {
+	case <-entered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the cleaner never refreshed the slow cache")
+	}
+
+	clk := newFakeClock()
+	fast := make([]*Cache, 4)
+	for i := range fast {
+		fast[i] = CreateCache(time.Minute, func(key string) interface{} { return key }, WithClock(clk))
+		defer fast[i].Implode()
+		fast[i].Set("k", "v")
+	}
+	clk.advance(2 * time.Minute)
+
+	eventually(t, "the fast caches weren't cleaned while the slow one was being refreshed", func() bool {
+		for _, c := range fast {
+			if c.Len() != 0 {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func TestImplodeDuringScrub(t *testing.T) {
+	StartCleaner(time.Millisecond)
+	defer StopCleaner()
+
+	var wg, scrubs sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				// every scrub of the cache has accessed, expired entries to refresh
+				c := CreateCache(time.Microsecond, func(key string) interface{} {
+					time.Sleep(time.Microsecond)
+					return key
+				}, WithCleanRate(time.Microsecond))
+				for k := 0; k < 8; k++ {
+					c.Retrieve(strconv.Itoa(k))
+				}
+
+				scrubs.Add(1)
+				go func() {
+					defer scrubs.Done()
+					c.RunScrubNow()
+				}()
+				c.Implode()
+			}
+		}()
+	}
+	wg.Wait()
+	scrubs.Wait()
+
+	// the cleaner survived, and keeps cleaning
+	awaitScrub(t)
+}
+
+func TestStartCleanerAfterAbandonedShutdown(t *testing.T) {
+	StartCleaner(time.Millisecond)
+	defer StopCleaner()
+
+	// a scrub which is stuck refreshing the slow cache's entry, until it's released
+	clk := newFakeClock()
+	entered, release := make(chan struct{}), make(chan struct{})
+	var calls atomic.Int32
+	slow := CreateCache(time.Minute, func(key string) interface{} {
+		if calls.Add(1) == 2 {
+			close(entered)
+			<-release
+		}
+		return key
+	}, WithClock(clk))
+	defer slow.Implode()
+
+	slow.Retrieve("k")
+	clk.advance(2 * time.Minute)
+	<-entered
+
+	p.launchMu.Lock()
+	oldDone := p.done
+	p.launchMu.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := Shutdown(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Shutdown = %v, want it to give up on the stuck scrub", err)
+	}
+
+	StartCleaner(time.Millisecond)
+	close(release)
+	<-oldDone
+
+	if _, _, running := CleanerStatus(); !running {
+		t.Error("the old scrubber's exit reported the new one as stopped")
+	}
+
+	// the new scrubber is the one cleaning now
+	awaitScrub(t)
+}
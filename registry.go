@@ -0,0 +1,75 @@
+// Package eagercache provides interfaces for threadsafe, mildly-generic, in-memory caches
+// These caches are intended to maximize performance as much as possible by helping to
+// minimize expensive operations such as locking OS calls (File Reads, Network calls, et cetera)
+// License: MIT
+package eagercache
+
+import "sort"
+
+// AllCaches returns every live Cache, sorted by the names given by WithName, for managing all of an
+// application's caches from one place, eg: an admin endpoint. The slice is a snapshot, caches created
+// or imploded afterwards aren't reflected in it. The shards of a ShardedCache are included, as
+// are the Caches embedded in TypedCaches. Caches created WithFinalizer are returned as
+// handles of their own, which don't keep them from being finalized.
+func AllCaches() []*Cache {
+	p.mu.RLock()
+	pooled := make([]*Cache, 0, len(p.pool))
+	for c := range p.pool {
+		if s, ok := c.(*cacheState); ok {
+			pooled = append(pooled, s.cache())
+		}
+	}
+	p.mu.RUnlock()
+
+	// checked without the pool lock, as Implode takes the cache's lock before the pool's
+	caches := pooled[:0]
+	for _, c := range pooled {
+		if !c.IsClosed() {
+			caches = append(caches, c)
+		}
+	}
+
+	sort.SliceStable(caches, func(i, j int) bool {
+		return caches[i].name < caches[j].name
+	})
+
+	return caches
+}
+
+// FlushAll calls Flush on every cache returned by AllCaches
+func FlushAll() {
+	for _, c := range AllCaches() {
+		c.Flush()
+	}
+}
+
+type (
+	// PoolTotals sums the sizes and counters of every live Cache, as returned by PoolStats
+	PoolTotals struct {
+		// Caches counts the live Caches
+		Caches int
+		// Entries sums their Len
+		Entries int
+		// Bytes sums their Bytes, for those created by CreateCacheWithByteLimit
+		Bytes int64
+		// Hits and Misses sum the counters of their Stats
+		Hits   uint64
+		Misses uint64
+	}
+)
+
+// PoolStats sums the sizes and hit and miss counters of every cache returned by AllCaches, as a few
+// process-wide numbers to watch for runaway cache growth. Each cache is read on its own, so the
+// totals aren't a consistent snapshot of the pool as a whole.
+func PoolStats() PoolTotals {
+	var t PoolTotals
+	for _, c := range AllCaches() {
+		t.Caches++
+		t.Entries += c.Len()
+		t.Bytes += c.Bytes()
+		t.Hits += c.stats.hits.Load()
+		t.Misses += c.stats.misses.Load()
+	}
+
+	return t
+}
@@ -0,0 +1,257 @@
+// Package eagercache provides interfaces for threadsafe, mildly-generic, in-memory caches
+// These caches are intended to maximize performance as much as possible by helping to
+// minimize expensive operations such as locking OS calls (File Reads, Network calls, et cetera)
+// License: MIT
+package eagercache
+
+import (
+	"encoding/binary"
+	"strings"
+)
+
+type (
+	// SecondaryStore is a slower, larger tier behind a Cache, eg: files on disk, for values which are
+	// costly to recompute but too large to all be kept in memory. Implementations must be threadsafe.
+	// The cache never deletes from a store which isn't also a SecondaryDeleter, and even then only
+	// the keys it explicitly drops, so it's up to the store to expire or bound what it holds.
+	SecondaryStore interface {
+		// Get returns the value Put for key, and whether there is one
+		Get(key string) ([]byte, bool)
+		// Put stores value for key, replacing any value already there
+		Put(key string, value []byte)
+	}
+
+	// SecondaryDeleter is implemented by the SecondaryStores which can drop a value, so that the
+	// values of keys removed from the cache are deleted from the store, rather than only ignored
+	SecondaryDeleter interface {
+		// Delete drops the value for key, if there is one
+		Delete(key string)
+	}
+
+	// secondaryFloor invalidates the values spilled for keys starting with prefix, or only for the
+	// key prefix if exact is set, up to and including version, see DeletePrefix and Flush
+	secondaryFloor struct {
+		prefix  string
+		exact   bool
+		version uint64
+	}
+)
+
+const (
+	// versionHeader is the length of the version every spilled value is prefixed with
+	versionHeader = 8
+
+	// maxSecondaryFloors is how many floors are kept before they're collapsed into a single one
+	// over every key, as Flush's
+	maxSecondaryFloors = 64
+)
+
+// WithSecondaryStore has entries which the cache evicts, because they expired without being read or
+// to stay within a size limit, spill to store rather than be discarded. A later miss on a spilled key
+// is filled from store, decoded by unmarshal, before falling back to the updater, and the value is
+// then cached as if the updater had returned it. Values are encoded for store by marshal. Explicitly
+// removed entries, by Delete, DeletePrefix, Flush or InvalidateIfOlder, aren't spilled, and their
+// spilled values are ignored from then on, and deleted if store is a SecondaryDeleter, so that the
+// next miss calls the updater. Values which fail to encode or decode are logged, see WithLogger, and
+// otherwise treated as though they were never spilled.
+//
+// The cache prefixes each value it Puts with a version of its own, so the bytes in store are
+// marshal's output behind an 8-byte header. What was spilled before a removal is ignored by its
+// version, which the cache keeps for each DeletePrefix, and for each spilled key Delete removes when
+// store isn't a SecondaryDeleter.
+// Past 64 of those, they're collapsed into one which ignores every value spilled so far, as Flush
+// does, at the cost of the spilled values which would still have been served.
+//
+// Spilled values are no fresher than when they were evicted, so only use a secondary store for data
+// which may be served that stale, or have store expire what it holds.
+func WithSecondaryStore(store SecondaryStore, marshal func(interface{}) ([]byte, error), unmarshal func([]byte) (interface{}, error)) Option {
+	if store == nil || marshal == nil || unmarshal == nil {
+		panic("the secondary store, marshal and unmarshal must all be non-nil")
+	}
+
+	return func(c *Cache) {
+		c.secondary = store
+		c.marshal = marshal
+		c.unmarshal = unmarshal
+	}
+}
+
+// toSecondary spills an evicted entry, whose value had version, to the secondary store, if the
+// cache has one. It must be called without c.mu held.
+func (c *Cache) toSecondary(key string, value interface{}, version uint64) {
+	// cached errors are only worth their short ttl, they aren't spilled
+	if c.secondary == nil || isCachedError(value) {
+		return
+	}
+
+	b, err := c.marshal(value)
+	if err != nil {
+		if c.logger != nil {
+			c.logger.Log(LogError, "marshal for secondary store failed", "cache", c.name, "key", key, "err", err)
+		}
+		return
+	}
+
+	spilled := make([]byte, versionHeader+len(b))
+	binary.BigEndian.PutUint64(spilled, version)
+	copy(spilled[versionHeader:], b)
+
+	c.secondary.Put(key, spilled)
+}
+
+// fromSecondary returns the value spilled to the secondary store for key, if there is one. It must
+// be called without c.mu held.
+func (c *Cache) fromSecondary(key string) (interface{}, bool) {
+	if c.secondary == nil {
+		return nil, false
+	}
+
+	b, ok := c.secondary.Get(key)
+	if !ok || len(b) < versionHeader {
+		return nil, false
+	}
+
+	// the value may have been spilled before DeletePrefix or Flush dropped key
+	if c.staleSpill(key, binary.BigEndian.Uint64(b)) {
+		if d, ok := c.secondary.(SecondaryDeleter); ok {
+			d.Delete(key)
+		}
+		return nil, false
+	}
+
+	v, err := c.unmarshal(b[versionHeader:])
+	if err != nil {
+		if c.logger != nil {
+			c.logger.Log(LogError, "unmarshal from secondary store failed", "cache", c.name, "key", key, "err", err)
+		}
+		return nil, false
+	}
+
+	return v, true
+}
+
+// dropSecondary deletes the values spilled for keys from the secondary store, if the cache has one,
+// after they were explicitly dropped from the cache. It must be called without c.mu held.
+func (c *Cache) dropSecondary(keys ...string) {
+	if c.secondary == nil {
+		return
+	}
+
+	d, canDelete := c.secondary.(SecondaryDeleter)
+	for _, key := range keys {
+		if canDelete {
+			d.Delete(key)
+		} else {
+			c.dropSpill(key, ^uint64(0))
+		}
+	}
+}
+
+// dropSpill drops the value spilled for key if its version is below version, and reports whether it
+// did. A store which can't delete has the value floored instead, so that only the keys which have
+// a value in store take up a floor. It must be called without c.mu held.
+func (c *Cache) dropSpill(key string, version uint64) bool {
+	b, ok := c.secondary.Get(key)
+	if !ok || len(b) < versionHeader || binary.BigEndian.Uint64(b) >= version {
+		return false
+	}
+
+	if d, ok := c.secondary.(SecondaryDeleter); ok {
+		d.Delete(key)
+		return true
+	}
+
+	c.mu.Lock()
+	c.addFloorLocked(secondaryFloor{prefix: key, exact: true, version: binary.BigEndian.Uint64(b)})
+	c.mu.Unlock()
+
+	return true
+}
+
+// floorSecondaryLocked invalidates the values spilled so far for every key starting with prefix,
+// including those which aren't in memory any more, and so can't be deleted one by one. The caller
+// must hold c.mu for writing.
+func (c *Cache) floorSecondaryLocked(prefix string) {
+	if c.secondary == nil {
+		return
+	}
+
+	c.addFloorLocked(secondaryFloor{prefix: prefix, version: c.version})
+}
+
+// addFloorLocked adds floor, dropping those it supersedes and those older than every value spilled,
+// which can't invalidate anything, and collapsing them all into one past maxSecondaryFloors. The
+// caller must hold c.mu for writing.
+func (c *Cache) addFloorLocked(floor secondaryFloor) {
+	kept := c.secondaryFloors[:0]
+	for _, f := range c.secondaryFloors {
+		if f.version < c.oldestSpill || floor.covers(f) {
+			continue
+		}
+		kept = append(kept, f)
+	}
+
+	// a floor which covers no spilled value isn't needed, nothing older is in store
+	if c.spills == 0 || floor.version < c.oldestSpill {
+		c.secondaryFloors = kept
+		return
+	}
+
+	if len(kept) >= maxSecondaryFloors {
+		kept = append(kept[:0], secondaryFloor{version: c.version})
+		c.secondaryFloors = kept
+		return
+	}
+
+	c.secondaryFloors = append(kept, floor)
+}
+
+// noteSpillLocked records that a value with version is about to be spilled, so floors older than
+// every spilled value can be dropped. The caller must hold c.mu for writing.
+func (c *Cache) noteSpillLocked(version uint64) {
+	if c.spills == 0 || version < c.oldestSpill {
+		c.oldestSpill = version
+	}
+	c.spills++
+}
+
+// covers reports whether every value f invalidates is invalidated by floor too
+func (floor secondaryFloor) covers(f secondaryFloor) bool {
+	if f.version > floor.version {
+		return false
+	}
+
+	if floor.exact {
+		return f.exact && f.prefix == floor.prefix
+	}
+
+	return strings.HasPrefix(f.prefix, floor.prefix)
+}
+
+// matches reports whether the floor invalidates a value spilled for key, with version
+func (floor secondaryFloor) matches(key string, version uint64) bool {
+	if version > floor.version {
+		return false
+	}
+
+	if floor.exact {
+		return key == floor.prefix
+	}
+
+	return strings.HasPrefix(key, floor.prefix)
+}
+
+// staleSpill reports whether a value spilled for key, with version, was invalidated since by
+// floorSecondaryLocked. It must be called without c.mu held.
+func (c *Cache) staleSpill(key string, version uint64) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, f := range c.secondaryFloors {
+		if f.matches(key, version) {
+			return true
+		}
+	}
+
+	return false
+}
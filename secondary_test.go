@@ -0,0 +1,142 @@
+package eagercache
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memStore is a SecondaryStore backed by a map
+type memStore struct {
+	mu sync.Mutex
+	m  map[string][]byte
+}
+
+func (s *memStore) Get(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.m[key]
+	return b, ok
+}
+
+func (s *memStore) Put(key string, value []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.m[key] = value
+}
+
+func (s *memStore) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.m, key)
+}
+
+// getPutStore is a SecondaryStore which can't delete, so it isn't a SecondaryDeleter
+type getPutStore struct {
+	s memStore
+}
+
+func (g *getPutStore) Get(key string) ([]byte, bool) { return g.s.Get(key) }
+func (g *getPutStore) Put(key string, value []byte)  { g.s.Put(key, value) }
+
+// spillingCache returns a cache of a single entry which spills to store, and whose updater
+// returns how many times it's been called
+func spillingCache(store SecondaryStore) *Cache {
+	var mu sync.Mutex
+	calls := 0
+
+	return CreateCacheWithLimit(time.Minute, 1, func(key string) interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+
+		calls++
+		return calls
+	}, WithoutPool(), WithSecondaryStore(store, func(v interface{}) ([]byte, error) {
+		return []byte(strconv.Itoa(v.(int))), nil
+	}, func(b []byte) (interface{}, error) {
+		return strconv.Atoi(string(b))
+	}))
+}
+
+func TestSecondaryStoreReload(t *testing.T) {
+	store := &memStore{m: map[string][]byte{}}
+	c := spillingCache(store)
+
+	c.Retrieve("a")
+	c.Retrieve("b") // evicts a to the store
+
+	if got := c.Retrieve("a"); got != 1 {
+		t.Fatalf("Retrieve(a) = %v, want the spilled 1", got)
+	}
+}
+
+func TestSecondaryStoreExplicitRemovals(t *testing.T) {
+	removals := map[string]func(c *Cache){
+		"Delete":            func(c *Cache) { c.Delete("a") },
+		"DeletePrefix":      func(c *Cache) { c.DeletePrefix("a") },
+		"Flush":             func(c *Cache) { c.Flush() },
+		"InvalidateIfOlder": func(c *Cache) { c.InvalidateIfOlder("a", ^uint64(0)) },
+	}
+
+	stores := map[string]func() SecondaryStore{
+		"deleter":   func() SecondaryStore { return &memStore{m: map[string][]byte{}} },
+		"getAndPut": func() SecondaryStore { return &getPutStore{s: memStore{m: map[string][]byte{}}} },
+	}
+
+	for storeName, newStore := range stores {
+		for name, remove := range removals {
+			newStore, remove := newStore, remove
+			t.Run(storeName+"/"+name, func(t *testing.T) {
+				c := spillingCache(newStore())
+
+				c.Retrieve("a")
+				c.Retrieve("b") // evicts a to the store
+				remove(c)
+
+				if got := c.Retrieve("a"); got != 3 {
+					t.Fatalf("Retrieve(a) = %v, want 3 from the updater, not the spilled value", got)
+				}
+			})
+		}
+	}
+}
+
+func TestSecondaryFloorsBounded(t *testing.T) {
+	c := spillingCache(&getPutStore{s: memStore{m: map[string][]byte{}}})
+
+	floors := func() int {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+		return len(c.secondaryFloors)
+	}
+
+	// nothing spilled yet, so nothing to invalidate
+	c.DeletePrefix("x")
+	if n := floors(); n != 0 {
+		t.Fatalf("%d floors before anything was spilled, want 0", n)
+	}
+
+	c.Retrieve("a")
+	c.Retrieve("b") // evicts a to the store
+
+	for i := 0; i < 10*maxSecondaryFloors; i++ {
+		c.DeletePrefix(strconv.Itoa(i))
+		c.Delete("a")
+	}
+	if n := floors(); n > maxSecondaryFloors {
+		t.Fatalf("%d floors, want at most %d", n, maxSecondaryFloors)
+	}
+
+	c.Flush()
+	if n := floors(); n != 1 {
+		t.Fatalf("%d floors after Flush, want 1", n)
+	}
+
+	if got := c.Retrieve("a"); got != 3 {
+		t.Fatalf("Retrieve(a) = %v, want 3 from the updater, not the spilled value", got)
+	}
+}
@@ -0,0 +1,119 @@
+// Package eagercache provides interfaces for threadsafe, mildly-generic, in-memory caches
+// These caches are intended to maximize performance as much as possible by helping to
+// minimize expensive operations such as locking OS calls (File Reads, Network calls, et cetera)
+// License: MIT
+package eagercache
+
+import (
+	"sync"
+	"time"
+)
+
+type (
+	// ShardedCache spreads its keys over several independent Caches, each with its own lock and
+	// map, so that concurrent writes of different keys rarely contend. Every shard is registered with
+	// the cleaner on its own, so a cleaning pass only ever locks one shard at a time.
+	ShardedCache struct {
+		shards []*Cache
+	}
+)
+
+// CreateShardedCache allocates a ShardedCache of the given number of shards. Each shard behaves
+// exactly like a Cache from CreateCache, sharing the one updater and opts. The keys of WithInitialKeys
+// are each filled once, in their own shard.
+//
+// The updater func is required and expected to be threadsafe.
+func CreateShardedCache(expireRate time.Duration, shards int, updater EntryUpdater, opts ...Option) *ShardedCache {
+	if shards < 1 {
+		panic("a sharded cache needs at least 1 shard")
+	}
+
+	// take the initial keys back from each shard before it warms up, they're routed below instead
+	var initialKeys []string
+	var waitInitial bool
+	opts = append(opts, func(c *Cache) {
+		initialKeys, waitInitial = c.initialKeys, c.waitInitial
+		c.initialKeys = nil
+	})
+
+	sc := &ShardedCache{shards: make([]*Cache, shards)}
+	for i := range sc.shards {
+		sc.shards[i] = CreateCache(expireRate, updater, opts...)
+	}
+
+	if len(initialKeys) > 0 {
+		sc.warmUp(initialKeys, waitInitial)
+	}
+
+	return sc
+}
+
+// warmUp is WithInitialKeys for the whole ShardedCache: every shard warms up the keys it holds, all
+// at once, and with wait set, warmUp returns once they're all done.
+func (sc *ShardedCache) warmUp(keys []string, wait bool) {
+	perShard := make(map[*Cache][]string, len(sc.shards))
+	for _, key := range keys {
+		c := sc.shard(key)
+		perShard[c] = append(perShard[c], key)
+	}
+
+	var wg sync.WaitGroup
+	for c, keys := range perShard {
+		wg.Add(1)
+		go func(c *Cache, keys []string) {
+			defer wg.Done()
+			_ = c.WarmUp(keys)
+		}(c, keys)
+	}
+
+	if wait {
+		wg.Wait()
+	}
+}
+
+// Retrieve a value from the key's shard. On a cache miss, calls the updater func with the provided key
+func (sc *ShardedCache) Retrieve(key string) interface{} {
+	return sc.shard(key).Retrieve(key)
+}
+
+// Delete drops the entry for key from its shard, see Cache.Delete
+func (sc *ShardedCache) Delete(key string) {
+	sc.shard(key).Delete(key)
+}
+
+// Len returns the number of entries held across all shards, see Cache.Len
+func (sc *ShardedCache) Len() int {
+	n := 0
+	for _, c := range sc.shards {
+		n += c.Len()
+	}
+
+	return n
+}
+
+// Implode implodes every shard, see Cache.Implode
+func (sc *ShardedCache) Implode() {
+	if sc == nil {
+		return
+	}
+
+	for _, c := range sc.shards {
+		c.Implode()
+	}
+}
+
+// shard picks key's shard by its FNV-1a hash
+func (sc *ShardedCache) shard(key string) *Cache {
+	return sc.shards[fnv32(key)%uint32(len(sc.shards))]
+}
+
+// fnv32 is the 32-bit FNV-1a hash of key, computed inline to keep lookups allocation-free
+func fnv32(key string) uint32 {
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+
+	return h
+}
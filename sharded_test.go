@@ -0,0 +1,94 @@
+package eagercache
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShardedCacheInitialKeys(t *testing.T) {
+	keys := make([]string, 64)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+
+	updater, calls := countingUpdater()
+	sc := CreateShardedCache(time.Minute, 8, updater, WithoutPool(), WithInitialKeys(keys, true))
+
+	if n := sc.Len(); n != len(keys) {
+		t.Errorf("Len() = %d, want %d, each key in its own shard only", n, len(keys))
+	}
+
+	for key, n := range calls() {
+		if n != 1 {
+			t.Errorf("updater called %d times for %s, want once", n, key)
+		}
+	}
+
+	for _, key := range keys {
+		if _, ok := sc.shard(key).Peek(key); !ok {
+			t.Errorf("%s isn't in its shard", key)
+		}
+	}
+}
+
+// benchKeys are the keys the benchmarks cycle through, formatted ahead of time
+var benchKeys = func() []string {
+	keys := make([]string, 1<<14)
+	for i := range keys {
+		keys[i] = "key:" + strconv.Itoa(i)
+	}
+	return keys
+}()
+
+// runGoroutines splits b.N calls of op over exactly g goroutines, each calling it with its own
+// goroutine number and the op's number
+func runGoroutines(b *testing.B, g int, op func(goroutine, i int)) {
+	var wg sync.WaitGroup
+	per := b.N/g + 1
+
+	b.ResetTimer()
+	for w := 0; w < g; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < per; i++ {
+				op(w, i)
+			}
+		}(w)
+	}
+	wg.Wait()
+}
+
+// BenchmarkShardedWrites compares a ShardedCache with a single-lock Cache on a write-heavy load,
+// where every op fills a key and deletes it again
+func BenchmarkShardedWrites(b *testing.B) {
+	// the caches are unpooled, so there's nothing to Implode once they're done with
+	updater := func(key string) interface{} { return key }
+
+	type cache interface {
+		Retrieve(key string) interface{}
+		Delete(key string)
+	}
+
+	caches := map[string]func() cache{
+		"single":  func() cache { return CreateCache(time.Minute, updater, WithoutPool()) },
+		"sharded": func() cache { return CreateShardedCache(time.Minute, 32, updater, WithoutPool()) },
+	}
+
+	for _, g := range []int{8, 32} {
+		for _, name := range []string{"single", "sharded"} {
+			g, newCache := g, caches[name]
+			b.Run(fmt.Sprintf("%s/goroutines=%d", name, g), func(b *testing.B) {
+				c := newCache()
+				runGoroutines(b, g, func(w, i int) {
+					key := benchKeys[(w*len(benchKeys)/g+i)%len(benchKeys)]
+					c.Retrieve(key)
+					c.Delete(key)
+				})
+			})
+		}
+	}
+}
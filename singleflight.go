@@ -0,0 +1,131 @@
+// Package eagercache provides interfaces for threadsafe, mildly-generic, in-memory caches
+// These caches are intended to maximize performance as much as possible by helping to
+// minimize expensive operations such as locking OS calls (File Reads, Network calls, et cetera)
+// License: MIT
+package eagercache
+
+import (
+	"context"
+	"sync"
+)
+
+type (
+	// flight is a single in-progress fill, shared by every goroutine that missed on its key
+	flight struct {
+		done  chan struct{}
+		entry expirable
+		err   error
+		// abandoned is set when the fill failed because its leader's ctx was done, which says nothing
+		// about the ctxs of those who joined it
+		abandoned bool
+		// joiners counts the callers which joined the flight after its leader, guarded by the stripe
+		joiners int
+	}
+
+	// flightGroup coalesces concurrent fills of the same key so the updater runs once per key,
+	// in the spirit of golang.org/x/sync/singleflight. It's guarded separately from the cache's
+	// data lock, which is never held while a fill is in flight, and striped by key, so that misses
+	// on different keys don't contend on a single lock to join or land their flights.
+	flightGroup struct {
+		stripes [flightStripes]flightStripe
+	}
+
+	// flightStripe holds the flights of the keys which hash to it
+	flightStripe struct {
+		mu      sync.Mutex
+		flights map[string]*flight
+	}
+)
+
+// flightStripes is how many stripes a flightGroup's flights are spread over
+const flightStripes = 32
+
+// do runs fill for key, which must be bounded by ctx, unless a fill for key is already in flight, in
+// which case it waits for and returns that fill's result instead. Waiters give up early when their
+// own ctx is done. When the fill they waited for failed because its leader's ctx was done, they try
+// again, joining or leading the next fill, so that no caller fails on another's deadline.
+func (g *flightGroup) do(ctx context.Context, key string, fill func() (expirable, error)) (expirable, error) {
+	for {
+		f, leader := g.join(key)
+		if leader {
+			g.run(ctx, key, f, fill)
+			return f.entry, f.err
+		}
+
+		select {
+		case <-f.done:
+			if f.abandoned && ctx.Err() == nil {
+				continue
+			}
+
+			return f.entry, f.err
+		case <-ctx.Done():
+			return expirable{}, ctx.Err()
+		}
+	}
+}
+
+// doAsync runs fill for key on a new goroutine, unless a fill for key is already in flight, in which
+// case there's nothing to do
+func (g *flightGroup) doAsync(key string, fill func() (expirable, error)) {
+	if f, leader := g.join(key); leader {
+		go g.run(context.Background(), key, f, fill)
+	}
+}
+
+// join returns the flight for key, starting one if there isn't any, in which case leader is set and
+// the caller must run it
+func (g *flightGroup) join(key string) (f *flight, leader bool) {
+	s := g.stripe(key)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if f, ok := s.flights[key]; ok {
+		f.joiners++
+		return f, false
+	}
+
+	if s.flights == nil {
+		s.flights = map[string]*flight{}
+	}
+
+	f = &flight{done: make(chan struct{})}
+	s.flights[key] = f
+
+	return f, true
+}
+
+// run calls fill, bounded by ctx, on behalf of everyone waiting on f, then lands the flight
+func (g *flightGroup) run(ctx context.Context, key string, f *flight, fill func() (expirable, error)) {
+	defer func() {
+		s := g.stripe(key)
+		s.mu.Lock()
+		delete(s.flights, key)
+		s.mu.Unlock()
+		close(f.done)
+	}()
+
+	f.entry, f.err = fill()
+	f.abandoned = f.err != nil && ctx.Err() != nil
+}
+
+// joined returns how many callers joined the flight for key after its leader, 0 when there's no
+// flight for key
+func (g *flightGroup) joined(key string) int {
+	s := g.stripe(key)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if f, ok := s.flights[key]; ok {
+		return f.joiners
+	}
+
+	return 0
+}
+
+// stripe returns the stripe holding key's flights
+func (g *flightGroup) stripe(key string) *flightStripe {
+	return &g.stripes[fnv32(key)%flightStripes]
+}
@@ -0,0 +1,142 @@
+package eagercache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// retrieveConcurrently has n goroutines Retrieve key from c at once, and waits for them all
+func retrieveConcurrently(c *Cache, key string, n int) {
+	var start, done sync.WaitGroup
+	start.Add(1)
+	done.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer done.Done()
+			start.Wait()
+			c.Retrieve(key)
+		}()
+	}
+
+	start.Done()
+	done.Wait()
+}
+
+// slowCountingCache returns a cache whose updater takes a millisecond, counting its calls in calls
+func slowCountingCache(calls *atomic.Int64) *Cache {
+	return CreateCache(time.Minute, func(key string) interface{} {
+		calls.Add(1)
+		time.Sleep(time.Millisecond)
+		return key
+	}, WithoutPool())
+}
+
+func TestConcurrentMissesShareOneUpdaterCall(t *testing.T) {
+	var calls atomic.Int64
+	c := slowCountingCache(&calls)
+
+	retrieveConcurrently(c, "cold", 100)
+
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("updater called %d times for 100 concurrent misses of one key, want once", n)
+	}
+}
+
+func TestJoinerOutlivesCancelledLeader(t *testing.T) {
+	entered := make(chan struct{})
+	var calls atomic.Int32
+	c := CreateCacheCtx(time.Minute, func(ctx context.Context, key string) (interface{}, error) {
+		if calls.Add(1) == 1 {
+			close(entered)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return key, nil
+	}, WithoutPool())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	led := make(chan error)
+	go func() {
+		_, err := c.RetrieveCtx(ctx, "k")
+		led <- err
+	}()
+	<-entered
+
+	type result struct {
+		v   interface{}
+		err error
+	}
+	joined := make(chan result)
+	go func() {
+		v, err := c.RetrieveOrError("k")
+		joined <- result{v, err}
+	}()
+
+	eventually(t, "the second Retrieve never joined the fill", func() bool {
+		return c.flights.joined("k") == 1
+	})
+	cancel()
+
+	if err := <-led; !errors.Is(err, context.Canceled) {
+		t.Errorf("the cancelled RetrieveCtx = %v, want context.Canceled", err)
+	}
+
+	if got := <-joined; got.err != nil || got.v != "k" {
+		t.Errorf("the joiner got %v, %v, want k from a fill of its own", got.v, got.err)
+	}
+}
+
+// BenchmarkColdKeyHerd has 100 goroutines miss the same cold key at once, reporting the updater
+// calls it cost, which should be 1 per op
+func BenchmarkColdKeyHerd(b *testing.B) {
+	var calls atomic.Int64
+	c := slowCountingCache(&calls)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		retrieveConcurrently(c, strconv.Itoa(i), 100)
+	}
+	b.StopTimer()
+
+	b.ReportMetric(float64(calls.Load())/float64(b.N), "updater-calls/op")
+}
+
+// BenchmarkMixedReadMiss has 64 goroutines read hot keys, and miss their own cold ones, in several
+// proportions. Each miss's updater call takes 50µs, as a fast backend would, and the fills of
+// different keys run in parallel, each only taking the cache's lock to store its value.
+func BenchmarkMixedReadMiss(b *testing.B) {
+	const goroutines = 64
+	hot := benchKeys[:len(benchKeys)/2]
+	cold := benchKeys[len(benchKeys)/2:]
+
+	for _, missPercent := range []int{1, 10, 50} {
+		missPercent := missPercent
+		b.Run(fmt.Sprintf("miss=%d%%", missPercent), func(b *testing.B) {
+			c := CreateCache(time.Minute, func(key string) interface{} {
+				time.Sleep(50 * time.Microsecond)
+				return key
+			}, WithoutPool())
+			for _, key := range hot {
+				c.Retrieve(key)
+			}
+
+			perGoroutine := len(cold) / goroutines
+			runGoroutines(b, goroutines, func(w, i int) {
+				if i%100 >= missPercent {
+					c.Retrieve(hot[(w*perGoroutine+i)%len(hot)])
+					return
+				}
+
+				key := cold[w*perGoroutine+i%perGoroutine]
+				c.Delete(key)
+				c.Retrieve(key)
+			})
+		})
+	}
+}
@@ -0,0 +1,78 @@
+// Package eagercache provides interfaces for threadsafe, mildly-generic, in-memory caches
+// These caches are intended to maximize performance as much as possible by helping to
+// minimize expensive operations such as locking OS calls (File Reads, Network calls, et cetera)
+// License: MIT
+package eagercache
+
+import "time"
+
+// Export returns a copy of every unexpired value in the cache, keyed by key, eg: to persist before a
+// restart and Import afterwards. Keys known to be absent, see CreateCacheFound, and errors cached by
+// WithErrorCaching are left out. The map is the caller's own, so it's safe to modify. Whether the
+// values themselves can be serialized is up to the caller. A cache WithKeyHasher doesn't keep its
+// original keys, so there's nothing to Import its entries under, and Export panics on it.
+func (c *Cache) Export() map[string]interface{} {
+	if c.keyHasher != nil {
+		panic("a cache WithKeyHasher can't be exported, its original keys aren't kept")
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	n := c.clock.Now()
+	entries := make(map[string]interface{}, len(c.data))
+	for key, entry := range c.data {
+		if entry.expiresAt.After(n) && !isAbsent(entry.value) {
+			entries[key] = entry.value
+		}
+	}
+
+	return entries
+}
+
+// Import stores every one of entries, as from Export, as though each had been passed to Set: they
+// expire expireRate from now, and are evicted at expiry unless read in the meantime, and a cache
+// WithKeyHasher stores them under the hashes of their keys. Import on an imploded cache is a no-op.
+func (c *Cache) Import(entries map[string]interface{}) {
+	for key, value := range entries {
+		c.validate(key, value)
+	}
+
+	var evicted []removal
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return
+	}
+
+	n := c.clock.Now()
+	for key, value := range entries {
+		key = c.storedKey(key)
+		entry := c.data[key]
+		entry.value = value
+		entry.expiresAt = c.expiresFrom(n, value, 0)
+		entry.createdAt = n
+		entry.version = c.nextVersionLocked()
+		entry.wasAccessedInInterval = false
+
+		evicted = append(evicted, c.touchLocked(key, &entry)...)
+		c.data[key] = entry
+	}
+	c.mu.Unlock()
+
+	c.notifyEvicted(evicted)
+}
+
+// Clone creates a new cache, registered with the cleaner as CreateCache would, with newExpireRate,
+// newUpdater and opts, and fills it with the unexpired values of c as of the call, with fresh
+// expiries, eg: to try out a different expireRate or updater on live data without recomputing it
+// all. The source is only read, under its read lock, and the two caches are independent from then
+// on. None of c's options carry over to the clone, only those passed in opts apply, so a clone
+// WithKeyHasher hashes the keys of c, while c itself can't be WithKeyHasher, as it can't be Exported.
+func (c *Cache) Clone(newExpireRate time.Duration, newUpdater EntryUpdater, opts ...Option) *Cache {
+	clone := CreateCache(newExpireRate, newUpdater, opts...)
+	clone.Import(c.Export())
+
+	return clone
+}
@@ -0,0 +1,52 @@
+package eagercache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+)
+
+func sha256Hex(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestExportImport(t *testing.T) {
+	updater := func(key string) interface{} { return "filled " + key }
+	src := CreateCache(time.Minute, updater, WithoutPool())
+	src.Set("a", "set a")
+	src.Set("b", "set b")
+
+	dst := CreateCache(time.Minute, updater, WithoutPool())
+	dst.Import(src.Export())
+
+	for _, key := range []string{"a", "b"} {
+		if v, ok := dst.Peek(key); !ok || v != "set "+key {
+			t.Errorf("Peek(%s) = %v, %v after Import, want the exported value", key, v, ok)
+		}
+	}
+}
+
+func TestImportHashesKeys(t *testing.T) {
+	updater := func(key string) interface{} { return "filled " + key }
+	src := CreateCache(time.Minute, updater, WithoutPool())
+	src.Set("a", "set a")
+
+	hashed := src.Clone(time.Minute, updater, WithoutPool(), WithKeyHasher(sha256Hex))
+	if v, ok := hashed.Peek("a"); !ok || v != "set a" {
+		t.Errorf("Peek(a) = %v, %v of the hashed clone, want the cloned value", v, ok)
+	}
+
+	hashed.Import(map[string]interface{}{"b": "imported b"})
+	if v := hashed.Retrieve("b"); v != "imported b" {
+		t.Errorf("Retrieve(b) = %v, want the imported value, not a miss", v)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Export of a hashed cache didn't panic")
+		}
+	}()
+	hashed.Export()
+}
@@ -0,0 +1,98 @@
+// Package eagercache provides interfaces for threadsafe, mildly-generic, in-memory caches
+// These caches are intended to maximize performance as much as possible by helping to
+// minimize expensive operations such as locking OS calls (File Reads, Network calls, et cetera)
+// License: MIT
+package eagercache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+type (
+	// Stats is a point-in-time copy of a cache's counters, as returned by Cache.Stats
+	Stats struct {
+		// Hits counts Retrieves served straight from the cache
+		Hits uint64
+		// Misses counts Retrieves that had to call the updater
+		Misses uint64
+		// Evictions counts entries removed by the cleaner, or to stay within a size limit
+		Evictions uint64
+		// Refreshes counts entries eagerly re-filled by the cleaner
+		Refreshes uint64
+
+		// UpdaterCalls counts every call of the updater, for misses and refreshes alike, failed or
+		// not. UpdaterMin, UpdaterMax, UpdaterMean and UpdaterP99 summarize how long they took, the
+		// p99 being approximate, to within 25%.
+		UpdaterCalls uint64
+		UpdaterMin   time.Duration
+		UpdaterMax   time.Duration
+		UpdaterMean  time.Duration
+		UpdaterP99   time.Duration
+
+		// DroppedEvents counts the events which weren't sent to a subscriber, see Subscribe
+		DroppedEvents uint64
+
+		// CircuitOpen is set while WithCircuitBreaker's circuit is open, and the updater isn't called
+		CircuitOpen bool
+	}
+
+	// cacheStats holds the live counters. They're atomics so that bumping them never contends on
+	// the cache's RWMutex.
+	cacheStats struct {
+		hits      atomic.Uint64
+		misses    atomic.Uint64
+		evictions atomic.Uint64
+		refreshes atomic.Uint64
+		latency   latencyHistogram
+	}
+)
+
+// Stats returns a snapshot of the cache's hit, miss, eviction and refresh counters, and of how long
+// its updater calls take. The counters are read individually, so a snapshot taken under load may be
+// off by the few operations in flight.
+func (c *Cache) Stats() Stats {
+	latency := c.stats.latency.summary()
+
+	return Stats{
+		Hits:         c.stats.hits.Load(),
+		Misses:       c.stats.misses.Load(),
+		Evictions:    c.stats.evictions.Load(),
+		Refreshes:    c.stats.refreshes.Load(),
+		UpdaterCalls: latency.count,
+		UpdaterMin:   latency.min,
+		UpdaterMax:   latency.max,
+		UpdaterMean:  latency.mean,
+		UpdaterP99:   latency.p99,
+
+		DroppedEvents: c.events.dropped.Load(),
+
+		CircuitOpen: c.breaker.open(c.clock.Now()),
+	}
+}
+
+// ResetStats zeroes all of the cache's counters, eg: between benchmarking runs.
+func (c *Cache) ResetStats() {
+	c.stats.hits.Store(0)
+	c.stats.misses.Store(0)
+	c.stats.evictions.Store(0)
+	c.stats.refreshes.Store(0)
+	c.stats.latency.reset()
+	c.events.dropped.Store(0)
+}
+
+// countHits records n hits, in both Stats and the MetricsRecorder
+func (c *Cache) countHits(n int) {
+	c.stats.hits.Add(uint64(n))
+	for i := 0; i < n; i++ {
+		c.metrics.RecordHit(c.name)
+	}
+}
+
+// countMisses records n misses, in both Stats and the MetricsRecorder
+func (c *Cache) countMisses(n int) {
+	c.stats.misses.Add(uint64(n))
+	for i := 0; i < n; i++ {
+		c.metrics.RecordMiss(c.name)
+	}
+}
@@ -0,0 +1,38 @@
+// Package eagercache provides interfaces for threadsafe, mildly-generic, in-memory caches
+// These caches are intended to maximize performance as much as possible by helping to
+// minimize expensive operations such as locking OS calls (File Reads, Network calls, et cetera)
+// License: MIT
+package eagercache
+
+type (
+	// Status is where a value returned by RetrieveWithStatus came from
+	Status int
+)
+
+const (
+	// StatusHit means the value was served straight from the cache
+	StatusHit Status = iota
+	// StatusMiss means the key was absent, and the updater was called to fill it
+	StatusMiss
+	// StatusExpiredRefreshed means the key's entry had expired, and the updater was called to
+	// replace it
+	StatusExpiredRefreshed
+	// StatusStale means the key's entry had expired, but was served anyway while the updater
+	// refreshes it in the background, see WithStaleWhileRevalidate
+	StatusStale
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusHit:
+		return "hit"
+	case StatusMiss:
+		return "miss"
+	case StatusExpiredRefreshed:
+		return "expired-refreshed"
+	case StatusStale:
+		return "stale"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,129 @@
+package eagercache
+
+import (
+	"errors"
+	"math/rand"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// stressCaches are the kinds of cache TestConcurrentStress hammers, which take different paths
+// through the fills, the scrubs and the evictions
+var stressCaches = []func(updater EntryUpdater) *Cache{
+	func(updater EntryUpdater) *Cache { return CreateCache(time.Millisecond, updater) },
+	func(updater EntryUpdater) *Cache { return CreateCacheWithLimit(time.Millisecond, 16, updater) },
+	func(updater EntryUpdater) *Cache {
+		return CreateCacheWithByteLimit(time.Millisecond, 16, func(interface{}) int64 { return 1 }, updater)
+	},
+	func(updater EntryUpdater) *Cache {
+		return CreateCache(time.Millisecond, updater, WithSlidingExpiration(), WithCleanRate(time.Millisecond))
+	},
+	func(updater EntryUpdater) *Cache {
+		return CreateCacheE(time.Millisecond, func(key string) (interface{}, error) {
+			if len(key)%3 == 0 {
+				return nil, errors.New("unlucky")
+			}
+			return updater(key), nil
+		}, WithErrorCaching(time.Millisecond))
+	},
+}
+
+// TestConcurrentStress has many goroutines Retrieve, Set, Delete and Implode at random, over caches
+// which are replaced once imploded, while the cleaner scrubs them. Run it under -race: it asserts
+// that nothing panics, and that the caches' counters and sizes stay consistent.
+func TestConcurrentStress(t *testing.T) {
+	StartCleaner(time.Millisecond)
+	defer StopCleaner()
+
+	ops := 5000
+	if testing.Short() {
+		ops = 500
+	}
+
+	updater := func(key string) interface{} { return key }
+	slots := make([]atomic.Pointer[Cache], len(stressCaches))
+	for i, create := range stressCaches {
+		slots[i].Store(create(updater))
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(seed))
+
+			for i := 0; i < ops; i++ {
+				slot := rng.Intn(len(slots))
+				c := slots[slot].Load()
+				key := strconv.Itoa(rng.Intn(64))
+
+				switch op := rng.Intn(100); {
+				case op < 50:
+					if v := c.Retrieve(key); v != nil && v != key {
+						t.Errorf("Retrieve(%s) = %v", key, v)
+					}
+				case op < 70:
+					c.Set(key, key)
+				case op < 85:
+					c.Delete(key)
+				case op < 90:
+					c.RetrieveMany([]string{key, "0", "1"})
+				case op < 95:
+					c.RunScrubNow()
+				case op < 97:
+					c.Flush()
+				default:
+					if c.Implode() {
+						slots[slot].Store(stressCaches[slot](updater))
+					}
+				}
+			}
+		}(int64(g))
+	}
+	wg.Wait()
+
+	for i := range slots {
+		c := slots[i].Load()
+		checkStressed(t, c)
+		c.Implode()
+	}
+}
+
+// checkStressed asserts the invariants of a cache TestConcurrentStress is done with
+func checkStressed(t *testing.T, c *Cache) {
+	t.Helper()
+
+	// a counter which was decremented past zero would wrap around to an absurd count
+	const absurd = 1 << 62
+	s := c.Stats()
+	for name, n := range map[string]uint64{
+		"Hits": s.Hits, "Misses": s.Misses, "Evictions": s.Evictions, "Refreshes": s.Refreshes,
+		"UpdaterCalls": s.UpdaterCalls,
+	} {
+		if n > absurd {
+			t.Errorf("Stats().%s = %d", name, n)
+		}
+	}
+
+	if s.UpdaterMin < 0 || s.UpdaterMean < s.UpdaterMin || s.UpdaterMax < s.UpdaterMean {
+		t.Errorf("updater latencies out of order: min %v, mean %v, max %v", s.UpdaterMin, s.UpdaterMean, s.UpdaterMax)
+	}
+
+	n := c.Len()
+	if n < 0 || n != len(c.Keys()) {
+		t.Errorf("Len() = %d, with %d Keys()", n, len(c.Keys()))
+	}
+
+	if c.maxEntries > 0 && n > c.maxEntries {
+		t.Errorf("Len() = %d over the limit of %d", n, c.maxEntries)
+	}
+
+	// every entry of the byte-limited cache is sized 1
+	if c.maxBytes > 0 && c.Bytes() != int64(n) {
+		t.Errorf("Bytes() = %d for %d entries of a byte each", c.Bytes(), n)
+	}
+}
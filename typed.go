@@ -0,0 +1,111 @@
+// Package eagercache provides interfaces for threadsafe, mildly-generic, in-memory caches
+// These caches are intended to maximize performance as much as possible by helping to
+// minimize expensive operations such as locking OS calls (File Reads, Network calls, et cetera)
+// License: MIT
+package eagercache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type (
+	// TypedCache is the statically-typed counterpart to Cache. Keys may be any comparable type and
+	// values are returned as V, so neither the updater nor callers of Retrieve need type assertions.
+	// It's a Cache underneath, so it's cleaned, refreshed and configured by Options exactly like one:
+	// the embedded Cache's methods take the string form of a key, as returned by Key.
+	TypedCache[K comparable, V any] struct {
+		*Cache
+		updater func(K) V
+
+		// keys maps the string form of every key filled through Retrieve back to the key itself, so
+		// the cleaner's refreshes, which only know the string, can call the typed updater
+		keys *sync.Map
+	}
+)
+
+// CreateTypedCache allocates a TypedCache, which behaves exactly like a Cache from CreateCache with
+// the same opts, calling the updater with the key given to Retrieve.
+//
+// The updater func is required and expected to be threadsafe. The expireRate must be positive.
+func CreateTypedCache[K comparable, V any](expireRate time.Duration, updater func(K) V, opts ...Option) *TypedCache[K, V] {
+	if updater == nil {
+		panic("the updater-func must be a non-nil reference to a func(K) V")
+	}
+
+	if expireRate <= 0 {
+		panic("the expireRate must be positive, entries would be born expired")
+	}
+
+	c := &TypedCache[K, V]{updater: updater, keys: new(sync.Map)}
+
+	// appended last, so it wraps whatever OnEvict the caller's opts registered
+	opts = append(opts[:len(opts):len(opts)], func(cache *Cache) {
+		onEvict := cache.onEvict
+		cache.onEvict = func(key string, value interface{}) {
+			c.keys.Delete(key)
+			if onEvict != nil {
+				onEvict(key, value)
+			}
+		}
+	})
+
+	c.Cache = newCache(expireRate, c.load, opts)
+
+	return c
+}
+
+// Key returns the string form of key, under which the embedded Cache stores it. Strings are used
+// as-is, any other key is formatted by %#v, along with its type.
+func (c *TypedCache[K, V]) Key(key K) string {
+	if s, ok := interface{}(key).(string); ok {
+		return s
+	}
+
+	return fmt.Sprintf("%T:%#v", key, key)
+}
+
+// Retrieve a value from the cache. On a cache miss, calls the updater func with the provided key.
+// Retrieving from an imploded cache returns the zero V.
+func (c *TypedCache[K, V]) Retrieve(key K) V {
+	s := c.Key(key)
+	if _, ok := c.keys.Load(s); !ok {
+		c.keys.Store(s, key)
+	}
+
+	v, _ := c.Cache.Retrieve(s).(V)
+	return v
+}
+
+// Implode is Cache.Implode, additionally releasing the keys the TypedCache kept for its refreshes
+func (c *TypedCache[K, V]) Implode() bool {
+	if c == nil || !c.Cache.Implode() {
+		return false
+	}
+
+	c.keys.Range(func(key, _ interface{}) bool {
+		c.keys.Delete(key)
+		return true
+	})
+
+	return true
+}
+
+// load is the embedded Cache's updater. A key it can't map back is one which was stored by the
+// embedded Cache's own methods, or whose mapping was dropped by the eviction of an earlier entry
+// racing its fill, so it fails like any other updater error, leaving the entry as it was.
+func (c *TypedCache[K, V]) load(_ context.Context, s string) (interface{}, time.Duration, error) {
+	key, ok := c.keys.Load(s)
+	if !ok {
+		// a string K needs no mapping
+		if k, isK := interface{}(s).(K); isK {
+			return c.updater(k), 0, nil
+		}
+
+		return nil, 0, fmt.Errorf("eagercache: %q isn't the key of a Retrieve", s)
+	}
+
+	return c.updater(key.(K)), 0, nil
+}
@@ -0,0 +1,87 @@
+package eagercache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTypedCachePanickingUpdater(t *testing.T) {
+	c := CreateTypedCache(time.Minute, func(key string) int {
+		if key == "bad" {
+			panic("boom")
+		}
+		return len(key)
+	}, WithoutPool())
+	defer c.Implode()
+
+	// recovered like any Cache's updater, nothing is stored
+	if got := c.Retrieve("bad"); got != 0 {
+		t.Errorf("Retrieve(bad) = %d, want the zero int", got)
+	}
+
+	if _, ok := c.Peek("bad"); ok {
+		t.Error("the panicking fill was stored")
+	}
+
+	if got := c.Retrieve("good"); got != 4 {
+		t.Errorf("Retrieve(good) = %d, want 4", got)
+	}
+}
+
+func TestTypedCacheKeepsUnexpiredRefresh(t *testing.T) {
+	clk := newFakeClock()
+	calls := 0
+	c := CreateTypedCache(time.Minute, func(key string) int {
+		calls++
+		return calls
+	}, WithClock(clk))
+	defer c.Implode()
+
+	c.Retrieve("k")
+	clk.advance(2 * time.Minute)
+
+	// the cleaner's refresh is stored unaccessed, but good for another interval
+	c.RunScrubNow()
+	if calls != 2 {
+		t.Fatalf("the refresh made %d updater calls in all, want 2", calls)
+	}
+
+	if got := c.Retrieve("k"); got != 2 || calls != 2 {
+		t.Fatalf("Retrieve(k) = %d after %d updater calls, want the refreshed 2 after 2", got, calls)
+	}
+}
+
+func TestTypedCacheStructKeys(t *testing.T) {
+	type tenantKey struct{ tenant, resource string }
+
+	clk := newFakeClock()
+	var mu sync.Mutex
+	var got []tenantKey
+	c := CreateTypedCache(time.Minute, func(k tenantKey) string {
+		mu.Lock()
+		got = append(got, k)
+		mu.Unlock()
+		return k.tenant + "/" + k.resource
+	}, WithClock(clk))
+
+	a, b := tenantKey{"a:b", "c"}, tenantKey{"a", "b:c"}
+	if c.Retrieve(a) != "a:b/c" || c.Retrieve(b) != "a/b:c" {
+		t.Fatal("keys which concatenate alike collided")
+	}
+
+	// the cleaner refreshes with the typed keys it was given
+	clk.advance(2 * time.Minute)
+	c.RunScrubNow()
+	if len(got) != 4 || got[2] == got[3] || (got[2] != a && got[2] != b) || (got[3] != a && got[3] != b) {
+		t.Errorf("updater called with %v, want a and b, then each again by the refresh", got)
+	}
+
+	if !c.Implode() || c.Implode() {
+		t.Error("Implode didn't report closing the cache exactly once")
+	}
+
+	if v := c.Retrieve(a); v != "" {
+		t.Errorf("Retrieve on an imploded cache = %q, want the zero string", v)
+	}
+}
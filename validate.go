@@ -0,0 +1,54 @@
+// Package eagercache provides interfaces for threadsafe, mildly-generic, in-memory caches
+// These caches are intended to maximize performance as much as possible by helping to
+// minimize expensive operations such as locking OS calls (File Reads, Network calls, et cetera)
+// License: MIT
+package eagercache
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// WithPointerValidation checks that every value about to be stored in the cache, whether from the
+// updater or Set, is a pointer, as EntryUpdater expects. A non-pointer panics with a message naming
+// its key, unless a handler was registered with WithValidationHandler. The refreshes of the cleaner
+// and RefreshMatching run on goroutines of their own, where a panic would crash the process, so
+// without a handler, a refresh to a non-pointer is logged, see WithLogger, and fails instead, keeping
+// the previous value. The check costs a reflect call per stored value, so it's meant for development
+// and tests rather than production.
+func WithPointerValidation() Option {
+	return func(c *Cache) {
+		c.validatePointers = true
+	}
+}
+
+// WithValidationHandler has WithPointerValidation report non-pointer values to f instead of
+// panicking. The value is cached regardless.
+func WithValidationHandler(f func(key string, err error)) Option {
+	return func(c *Cache) {
+		c.onInvalid = f
+	}
+}
+
+// validate enforces WithPointerValidation on a value about to be stored under key
+func (c *Cache) validate(key string, v interface{}) {
+	err := c.invalid(key, v)
+	if err == nil {
+		return
+	}
+
+	if c.onInvalid == nil {
+		panic(err)
+	}
+
+	c.onInvalid(key, err)
+}
+
+// invalid returns why v may not be stored under key, per WithPointerValidation, or nil if it may
+func (c *Cache) invalid(key string, v interface{}) error {
+	if !c.validatePointers || reflect.ValueOf(v).Kind() == reflect.Ptr || isAbsent(v) {
+		return nil
+	}
+
+	return fmt.Errorf("eagercache: the value for key %q is a %T, not a pointer", key, v)
+}
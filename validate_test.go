@@ -0,0 +1,61 @@
+package eagercache
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPointerValidation(t *testing.T) {
+	c := CreateCache(time.Minute, func(key string) interface{} { return key }, WithoutPool(), WithPointerValidation())
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Set of a non-pointer didn't panic")
+		}
+	}()
+	c.Set("k", "v")
+}
+
+func TestPointerValidationOnRefresh(t *testing.T) {
+	clk := newFakeClock()
+	ptr := new(int)
+	var calls atomic.Int32
+	updater := func(key string) interface{} {
+		if calls.Add(1) == 1 {
+			return ptr
+		}
+		return "not a pointer"
+	}
+
+	// without a handler, the refresh fails and the previous value is kept, no panic
+	c := CreateCache(time.Minute, updater, WithClock(clk), WithPointerValidation())
+	defer c.Implode()
+
+	c.Retrieve("k")
+	clk.advance(2 * time.Minute)
+	c.RunScrubNow()
+
+	if v := c.Retrieve("k"); v != ptr {
+		t.Errorf("Retrieve(k) = %v after an invalid refresh, want the previous value", v)
+	}
+
+	// with one, the handler is told and the value is cached regardless
+	calls.Store(0)
+	var reported atomic.Int32
+	h := CreateCache(time.Minute, updater, WithClock(clk), WithPointerValidation(),
+		WithValidationHandler(func(key string, err error) { reported.Add(1) }))
+	defer h.Implode()
+
+	h.Retrieve("k")
+	clk.advance(2 * time.Minute)
+	h.RunScrubNow()
+
+	if n := reported.Load(); n != 1 {
+		t.Errorf("the handler was told %d times, want once", n)
+	}
+
+	if v, _ := h.Peek("k"); v != "not a pointer" {
+		t.Errorf("Peek(k) = %v, want the refreshed value cached regardless", v)
+	}
+}
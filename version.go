@@ -0,0 +1,66 @@
+// Package eagercache provides interfaces for threadsafe, mildly-generic, in-memory caches
+// These caches are intended to maximize performance as much as possible by helping to
+// minimize expensive operations such as locking OS calls (File Reads, Network calls, et cetera)
+// License: MIT
+package eagercache
+
+import "context"
+
+// RetrieveVersioned is Retrieve, also returning the version of the returned value. Every value the
+// cache stores, whether it's filled on a miss, refreshed by the cleaner, or Set, gets a version
+// greater than those of every value stored before it, so of two versions of a key, the greater is
+// the fresher. Versions are only ordered within the one cache; they're a count, not a clock, and
+// can't be compared between caches or processes. ok is false when no value could be retrieved, ie:
+// the updater failed, now or as cached by WithErrorCaching, or the cache is imploded.
+func (c *Cache) RetrieveVersioned(key string) (value interface{}, version uint64, ok bool) {
+	cached, _, err := c.retrieve(context.Background(), key, nil)
+	if err != nil || isCachedError(cached.value) {
+		return nil, 0, false
+	}
+
+	return c.copyOut(cached.value), cached.version, true
+}
+
+// InvalidateIfOlder drops the entry for key if its version is older than version, and reports
+// whether it did. An invalidation message which carries the version that was current when it was
+// sent can then be delivered any number of times, and late, without dropping a value stored since.
+// Like Delete, it calls OnEvict for the entry, but doesn't count it as an Eviction.
+func (c *Cache) InvalidateIfOlder(key string, version uint64) bool {
+	key = c.storedKey(key)
+
+	c.mu.Lock()
+	entry, ok := c.data[key]
+	if !ok {
+		c.mu.Unlock()
+		return c.invalidateSpill(key, version)
+	}
+
+	if entry.version >= version {
+		c.mu.Unlock()
+		return false
+	}
+
+	c.removeLocked(key, entry)
+	c.mu.Unlock()
+
+	c.dropSecondary(key)
+
+	c.evicted(key, entry.value)
+
+	return true
+}
+
+// invalidateSpill is InvalidateIfOlder for a key which is only held by the secondary store, if at all
+func (c *Cache) invalidateSpill(key string, version uint64) bool {
+	if c.secondary == nil {
+		return false
+	}
+
+	return c.dropSpill(key, version)
+}
+
+// nextVersionLocked returns the version to stamp on a value being stored, c.mu must be held
+func (c *Cache) nextVersionLocked() uint64 {
+	c.version++
+	return c.version
+}
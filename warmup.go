@@ -0,0 +1,87 @@
+// Package eagercache provides interfaces for threadsafe, mildly-generic, in-memory caches
+// These caches are intended to maximize performance as much as possible by helping to
+// minimize expensive operations such as locking OS calls (File Reads, Network calls, et cetera)
+// License: MIT
+package eagercache
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+type (
+	// WarmUpError is returned by WarmUp when the updater failed for some of the keys. Failed maps
+	// each of those keys to the updater's error.
+	WarmUpError struct {
+		Failed map[string]error
+	}
+)
+
+func (e *WarmUpError) Error() string {
+	keys := make([]string, 0, len(e.Failed))
+	for key := range e.Failed {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return fmt.Sprintf("eagercache: warm up failed for %d key(s), first %q: %v", len(keys), keys[0], e.Failed[keys[0]])
+}
+
+// WarmUp fills keys through the updater ahead of traffic, so the first Retrieve of each is a hit.
+// Keys which are already present and unexpired are skipped, and duplicates are only filled once.
+// The updater calls run concurrently under the same limit as the cleaner's refreshes, see
+// WithRefreshConcurrency. Warmed entries aren't marked as accessed, so keys which then go unread
+// are evicted at expiry like any other.
+//
+// A *WarmUpError is returned if the updater failed for any key, which is only possible for caches
+// with a fallible updater such as from CreateCacheE. The failed keys are left uncached.
+func (c *Cache) WarmUp(keys []string) error {
+	c.mu.RLock()
+	if c.closed {
+		c.mu.RUnlock()
+		return ErrCacheClosed
+	}
+
+	n := c.clock.Now()
+	seen := make(map[string]struct{}, len(keys))
+	todo := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if _, dup := seen[key]; dup {
+			continue
+		}
+		seen[key] = struct{}{}
+
+		if entry, ok := c.data[c.storedKey(key)]; ok && entry.expiresAt.After(n) {
+			continue
+		}
+		todo = append(todo, key)
+	}
+	c.mu.RUnlock()
+
+	errs := make([]error, len(todo))
+	boundedRun(c.refreshLimit(), len(todo), func(i int) {
+		ctx := context.Background()
+		_, errs[i] = c.flights.do(ctx, todo[i], func() (expirable, error) {
+			return c.fillEntry(ctx, todo[i], nil, false)
+		})
+	})
+
+	var failed map[string]error
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+
+		if failed == nil {
+			failed = map[string]error{}
+		}
+		failed[todo[i]] = err
+	}
+
+	if failed != nil {
+		return &WarmUpError{Failed: failed}
+	}
+
+	return nil
+}
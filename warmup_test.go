@@ -0,0 +1,126 @@
+package eagercache
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingUpdater returns an EntryUpdater which returns its key, and a func reporting how many
+// times it was called for each key
+func countingUpdater() (EntryUpdater, func() map[string]int) {
+	var mu sync.Mutex
+	calls := map[string]int{}
+
+	return func(key string) interface{} {
+			mu.Lock()
+			defer mu.Unlock()
+
+			calls[key]++
+			return key
+		}, func() map[string]int {
+			mu.Lock()
+			defer mu.Unlock()
+
+			snapshot := make(map[string]int, len(calls))
+			for k, v := range calls {
+				snapshot[k] = v
+			}
+			return snapshot
+		}
+}
+
+func TestWithInitialKeys(t *testing.T) {
+	keys := []string{"a", "b", "c"}
+	updater, calls := countingUpdater()
+	c := CreateCache(time.Minute, updater, WithoutPool(), WithInitialKeys(keys, true))
+
+	for _, key := range keys {
+		if v, ok := c.Peek(key); !ok || v != key {
+			t.Errorf("Peek(%s) = %v, %v, want it present once the constructor returned", key, v, ok)
+		}
+	}
+
+	if got := calls(); len(got) != len(keys) {
+		t.Errorf("updater calls = %v, want one per key", got)
+	}
+}
+
+func TestWithInitialKeysBatchFill(t *testing.T) {
+	c := CreateCacheBatchFill(time.Minute, func(key string) map[string]interface{} {
+		return map[string]interface{}{key: key, key + "-related": key}
+	}, WithoutPool(), WithInitialKeys([]string{"a"}, true))
+
+	if _, ok := c.Peek("a-related"); !ok {
+		t.Fatal("the warm-up didn't go through the batch updater")
+	}
+}
+
+func TestWarmUp(t *testing.T) {
+	updater, calls := countingUpdater()
+	c := CreateCache(time.Minute, updater, WithoutPool())
+
+	c.Retrieve("present")
+	if err := c.WarmUp([]string{"a", "b", "a", "present", "c"}); err != nil {
+		t.Fatalf("WarmUp: %v", err)
+	}
+
+	for _, key := range []string{"a", "b", "c", "present"} {
+		if v, ok := c.Peek(key); !ok || v != key {
+			t.Errorf("Peek(%s) = %v, %v after WarmUp, want it present", key, v, ok)
+		}
+	}
+
+	for key, n := range calls() {
+		if n != 1 {
+			t.Errorf("updater called %d times for %s, want once", n, key)
+		}
+	}
+}
+
+func TestWarmUpError(t *testing.T) {
+	errDown := errors.New("down")
+	c := CreateCacheE(time.Minute, func(key string) (interface{}, error) {
+		if key == "bad" {
+			return nil, errDown
+		}
+		return key, nil
+	}, WithoutPool())
+
+	err := c.WarmUp([]string{"good", "bad"})
+
+	var warmErr *WarmUpError
+	if !errors.As(err, &warmErr) || len(warmErr.Failed) != 1 || !errors.Is(warmErr.Failed["bad"], errDown) {
+		t.Fatalf("WarmUp = %v, want a *WarmUpError failing only bad", err)
+	}
+
+	if _, ok := c.Peek("good"); !ok {
+		t.Error("good wasn't warmed up alongside the failing key")
+	}
+
+	if _, ok := c.Peek("bad"); ok {
+		t.Error("bad was cached, though its fill failed")
+	}
+}
+
+func TestWithInitialKeysInBackground(t *testing.T) {
+	keys := []string{"a", "b", "c"}
+	updater, calls := countingUpdater()
+	c := CreateCache(time.Minute, updater, WithoutPool(), WithInitialKeys(keys, false))
+
+	// a Retrieve racing the warm-up shares its updater call
+	if v := c.Retrieve("a"); v != "a" {
+		t.Errorf("Retrieve(a) = %v, want a", v)
+	}
+
+	eventually(t, "the background warm-up never filled every key", func() bool {
+		return c.Len() == len(keys)
+	})
+
+	for key, n := range calls() {
+		if n != 1 {
+			t.Errorf("updater called %d times for %s, want once", n, key)
+		}
+	}
+}